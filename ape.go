@@ -0,0 +1,197 @@
+package id3v2
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	apeFooterSize = 32         // size of an APE tag header or footer
+	apeHasHeader  = 0x80000000 // tag flag: the tag has a header
+	apeIsHeader   = 0x20000000 // tag flag: this is the header
+	apeItemType   = 0x00000006 // item flags: type of the value
+	apeBinary     = 0x00000002 // item type: binary data
+	apeLocator    = 0x00000004 // item type: external locator (URL)
+	maxTrailers   = 4          // maximum count of tags before the ID3v1 tag
+)
+
+// APEItem is an item of an APE tag.
+type APEItem struct {
+	Key   string
+	Flags uint32 // item flags (bits 1-2 give the type of the value)
+	Value []byte // raw value
+}
+
+// Binary tells if the value of the item is binary data rather than UTF-8
+// text.
+func (it *APEItem) Binary() bool {
+	return it.Flags&apeItemType == apeBinary
+}
+
+// Values returns the values of a text item. A text item may hold several
+// values, separated by nulls.
+func (it *APEItem) Values() []string {
+	return strings.Split(string(it.Value), "\x00")
+}
+
+// APETag is an APEv1 or APEv2 tag, as written by foobar2000 or MP3Gain
+// (ReplayGain and MP3GAIN_UNDO items) before the ID3v1 tag of a file.
+type APETag struct {
+	Version uint32 // 1000 for APEv1, 2000 for APEv2
+	Flags   uint32 // tag flags
+	Offset  int64  // file offset of the tag, header included
+	Size    int64  // size of the tag, header and footer included
+	Items   []APEItem
+}
+
+// Item returns the item of a given key, if any. Keys are case insensitive.
+func (t *APETag) Item(key string) *APEItem {
+	for i := range t.Items {
+		if strings.EqualFold(t.Items[i].Key, key) {
+			return &t.Items[i]
+		}
+	}
+	return nil
+}
+
+// Read the APE tag whose footer ends at offset end, if any.
+func readAPE(sf io.ReaderAt, end int64) (*APETag, error) {
+	if end < apeFooterSize {
+		return nil, nil
+	}
+	f := make([]byte, apeFooterSize)
+	_, err := sf.ReadAt(f, end-apeFooterSize)
+	if err != nil {
+		return nil, err
+	}
+
+	// Preamble         8 bytes ("APETAGEX")
+	// Version          4 bytes
+	// Tag size         4 bytes (items and footer, header excluded)
+	// Item count       4 bytes
+	// Tag flags        4 bytes
+	// Reserved         8 bytes
+	if string(f[:8]) != "APETAGEX" {
+		return nil, nil
+	}
+	le := binary.LittleEndian
+	t := &APETag{Version: le.Uint32(f[8:]), Flags: le.Uint32(f[20:])}
+	size := int64(le.Uint32(f[12:]))
+	count := le.Uint32(f[16:])
+	if size < apeFooterSize || size > end {
+		return nil, errors.New(fmt.Sprintf("Invalid APE tag size (0x%x bytes)", size))
+	}
+	t.Offset, t.Size = end-size, size
+	if t.Flags&apeHasHeader != 0 {
+		t.Offset -= apeFooterSize
+		t.Size += apeFooterSize
+	}
+	if t.Offset < 0 {
+		return nil, errors.New(fmt.Sprintf("Invalid APE tag size (0x%x bytes)", size))
+	}
+	if Verbose >= 2 {
+		fmt.Printf("APE tag at 0x%x: version %d, 0x%x bytes, %d items\n", t.Offset, t.Version, t.Size, count)
+	}
+
+	// Read the items.
+	b := make([]byte, size-apeFooterSize)
+	_, err = sf.ReadAt(b, end-size)
+	if err != nil {
+		return nil, err
+	}
+
+	// Value size       4 bytes
+	// Item flags       4 bytes
+	// Key              <ASCII string> $00
+	// Value            <value size bytes>
+	for i := uint32(0); i < count; i++ {
+		if len(b) < 9 {
+			return nil, errors.New(fmt.Sprintf("Truncated APE item #%d", i))
+		}
+		vsz := le.Uint32(b)
+		flags := le.Uint32(b[4:])
+		k := bytes.IndexByte(b[8:], 0x00)
+		if k == -1 || uint64(vsz) > uint64(len(b)-8-k-1) {
+			return nil, errors.New(fmt.Sprintf("Truncated APE item #%d", i))
+		}
+		key := string(b[8 : 8+k])
+		b = b[8+k+1:]
+		t.Items = append(t.Items, APEItem{Key: key, Flags: flags, Value: b[:vsz]})
+		b = b[vsz:]
+	}
+	return t, nil
+}
+
+// Read the APE, Lyrics3v2 and ID3v2 tags that end at offset end (the
+// beginning of the ID3v1 tag, or the end of the file of a given size), in
+// any order, and add their items to the processed tags.
+func (mi *MP3Info) readAppendedTags(sf io.ReaderAt, size, end int64) error {
+	for n := 0; n < maxTrailers; n++ {
+		pos, err := findAppendedTag(sf, end)
+		if err != nil {
+			return err
+		}
+		if pos >= 0 {
+			if Verbose >= 2 {
+				fmt.Printf("Appended ID3v2 tag at 0x%x\n", pos)
+			}
+			if !mi.tagDone(pos) {
+				_, _, err = mi.readTag(sf, size, pos)
+				if err != nil {
+					return err
+				}
+				mi.Layouts[len(mi.Layouts)-1].Appended = true
+			}
+			end = pos
+			continue
+		}
+		ape, err := readAPE(sf, end)
+		if err != nil {
+			return err
+		}
+		if ape != nil {
+			mi.APE = ape
+			mi.addAPE(ape)
+			end = ape.Offset
+			continue
+		}
+		l3, err := readLyrics3(sf, end)
+		if err != nil {
+			return err
+		}
+		if l3 != nil {
+			mi.Lyrics3 = l3
+			mi.addLyrics3(l3)
+			end = l3.Offset
+			continue
+		}
+		break
+	}
+	return nil
+}
+
+// Add the items of an APE tag to the processed tags. Their keys in
+// mi.AllTags are prefixed with "APE:".
+func (mi *MP3Info) addAPE(t *APETag) {
+	for i := range t.Items {
+		it := &t.Items[i]
+		pt := &ProcessedTag{Name: it.Key, ID: it.Key, Data: it, Origin: OriginAPE}
+		switch {
+		case it.Binary():
+			pt.Value = fmt.Sprintf("%d bytes", len(it.Value))
+		case it.Flags&apeItemType == apeLocator:
+			pt.Value = string(it.Value)
+		default:
+			pt.Value = strings.Join(it.Values(), "/")
+		}
+		if Verbose >= 1 {
+			fmt.Printf("%s (APE): %s\n", pt.Name, pt.Value)
+		}
+		mi.AllTags["APE:"+it.Key] = pt
+		mi.Frames = append(mi.Frames, pt)
+	}
+}
@@ -0,0 +1,162 @@
+package id3v2
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// Return an APEv2 tag, with a header if header is set, made of items of a
+// key, flags and value.
+func apeTag(header bool, items ...APEItem) []byte {
+	var body []byte
+	le := binary.LittleEndian
+	for _, it := range items {
+		body = le.AppendUint32(body, uint32(len(it.Value)))
+		body = le.AppendUint32(body, it.Flags)
+		body = append(body, it.Key...)
+		body = append(body, 0)
+		body = append(body, it.Value...)
+	}
+	hf := func(flags uint32) []byte {
+		b := []byte("APETAGEX")
+		b = le.AppendUint32(b, 2000)
+		b = le.AppendUint32(b, uint32(len(body)+apeFooterSize))
+		b = le.AppendUint32(b, uint32(len(items)))
+		b = le.AppendUint32(b, flags)
+		return append(b, make([]byte, 8)...)
+	}
+	var b []byte
+	flags := uint32(0)
+	if header {
+		flags = apeHasHeader
+		b = hf(flags | apeIsHeader)
+	}
+	b = append(b, body...)
+	return append(b, hf(flags)...)
+}
+
+// Return a Lyrics3v2 tag made of fields of a 3-char ID and a value.
+func lyrics3Tag(fields ...string) []byte {
+	b := []byte(lyrics3Begin)
+	for i := 0; i+1 < len(fields); i += 2 {
+		b = append(b, fmt.Sprintf("%s%05d%s", fields[i], len(fields[i+1]), fields[i+1])...)
+	}
+	return append(b, fmt.Sprintf("%06d%s", len(b), lyrics3End)...)
+}
+
+func TestAppendedTags(t *testing.T) {
+	audio := append(append([]byte{}, mpegFrame...), make([]byte, 400)...)
+	items := []APEItem{
+		{Key: "REPLAYGAIN_TRACK_GAIN", Value: []byte("-6.50 dB")},
+		{Key: "Artist", Value: []byte("A1\x00A2")},
+		{Key: "Cover Art (Front)", Flags: apeBinary, Value: []byte("cover.jpg\x00\xff\xd8\xff")},
+		{Key: "Related", Flags: apeLocator, Value: []byte("http://example.com/")},
+	}
+	ape, l3 := apeTag(true, items...), lyrics3Tag("IND", "10", "LYR", "[00:01]La la\r\nLa", "ETT", "Extended t\xeftle")
+	v1 := v1Tag("Title", "Artist", "", "", "", 0, 255)
+	for _, tc := range []struct {
+		name     string
+		trailers []byte
+		ape, l3  int // offsets of the tags in the trailers, -1 if none
+	}{
+		{"APE", append(append([]byte{}, ape...), v1...), 0, -1},
+		{"APE without header", append(apeTag(false, items...), v1...), 0, -1},
+		{"APE without ID3v1", ape, 0, -1},
+		{"Lyrics3", append(append([]byte{}, l3...), v1...), -1, 0},
+		{"Lyrics3 then APE", append(append(append([]byte{}, l3...), ape...), v1...), len(l3), 0},
+		{"APE then Lyrics3", append(append(append([]byte{}, ape...), l3...), v1...), 0, len(ape)},
+	} {
+		b := append(append([]byte{}, audio...), tc.trailers...)
+		mi, err := ReadAllTags(bytes.NewReader(b), int64(len(b)))
+		if err != nil {
+			t.Fatalf("%s: %s", tc.name, err)
+		}
+		if mi.AudioStart != 0 || mi.BitRate != 128 {
+			t.Errorf("%s: bitrate %d, audio at %d", tc.name, mi.BitRate, mi.AudioStart)
+		}
+
+		if tc.ape < 0 {
+			if mi.APE != nil {
+				t.Errorf("%s: APE tag %+v", tc.name, mi.APE)
+			}
+		} else if mi.APE == nil {
+			t.Errorf("%s: no APE tag", tc.name)
+		} else {
+			at := mi.APE
+			size := int64(len(apeTag(at.Flags&apeHasHeader != 0, items...)))
+			if at.Version != 2000 || at.Offset != int64(len(audio)+tc.ape) || at.Size != size {
+				t.Errorf("%s: APE version %d, offset %d, size %d", tc.name, at.Version, at.Offset, at.Size)
+			}
+			if !reflect.DeepEqual(at.Items, items) {
+				t.Errorf("%s: APE items %q", tc.name, at.Items)
+			}
+			if it := at.Item("artist"); it == nil || it.Binary() || !reflect.DeepEqual(it.Values(), []string{"A1", "A2"}) {
+				t.Errorf("%s: APE artist %+v", tc.name, it)
+			}
+			for key, want := range map[string]string{
+				"REPLAYGAIN_TRACK_GAIN": "-6.50 dB",
+				"Artist":                "A1/A2",
+				"Cover Art (Front)":     "13 bytes",
+				"Related":               "http://example.com/",
+			} {
+				if pt := mi.AllTags["APE:"+key]; pt == nil || pt.Value != want || pt.Origin != OriginAPE {
+					t.Errorf("%s: APE:%s %+v", tc.name, key, pt)
+				}
+			}
+		}
+
+		if tc.l3 < 0 {
+			if mi.Lyrics3 != nil {
+				t.Errorf("%s: Lyrics3 tag %+v", tc.name, mi.Lyrics3)
+			}
+		} else if mi.Lyrics3 == nil {
+			t.Errorf("%s: no Lyrics3 tag", tc.name)
+		} else {
+			lt := mi.Lyrics3
+			if lt.Offset != int64(len(audio)+tc.l3) || lt.Size != int64(len(l3)) {
+				t.Errorf("%s: Lyrics3 offset %d, size %d", tc.name, lt.Offset, lt.Size)
+			}
+			want := []Lyrics3Field{{"IND", "10"}, {"LYR", "[00:01]La la\r\nLa"}, {"ETT", "Extended tïtle"}}
+			if !reflect.DeepEqual(lt.Fields, want) {
+				t.Errorf("%s: Lyrics3 fields %q", tc.name, lt.Fields)
+			}
+			if pt := mi.AllTags["LYRICS3:ETT"]; pt == nil || pt.Name != "Extended title" || pt.Value != "Extended tïtle" || pt.Origin != OriginLyrics3 {
+				t.Errorf("%s: LYRICS3:ETT %+v", tc.name, pt)
+			}
+		}
+
+		// The ID3v1 tag is read past the other tags.
+		if pt := mi.AllTags["TIT2"]; bytes.HasSuffix(b, v1) && (pt == nil || pt.Value != "Title") {
+			t.Errorf("%s: TIT2 %+v", tc.name, pt)
+		}
+	}
+}
+
+func TestAppendedTagsInvalid(t *testing.T) {
+	v1 := v1Tag("Title", "", "", "", "", 0, 255)
+	ape := apeTag(false, APEItem{Key: "Key", Value: []byte("value")})
+	binary.LittleEndian.PutUint32(ape[len(ape)-apeFooterSize+12:], 0x10000) // tag size
+	l3 := lyrics3Tag("LYR", "lyrics")
+	copy(l3[len(l3)-15:], "9999xx")
+	item := apeTag(false, APEItem{Key: "Key", Value: []byte("value")})
+	item[0] = 0x50 // value size
+	for name, trailers := range map[string][]byte{
+		"APE size":     append(ape, v1...),
+		"Lyrics3 size": append(l3, v1...),
+		"APE item":     append(item, v1...),
+	} {
+		b := append(append([]byte{}, mpegFrame...), trailers...)
+		if _, err := ReadAllTags(bytes.NewReader(b), int64(len(b))); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+		mi, err := ReadAllTagsWith(bytes.NewReader(b), int64(len(b)), &Options{Lenient: true})
+		if err != nil {
+			t.Errorf("%s: %s in lenient mode", name, err)
+		} else if pt := mi.AllTags["TIT2"]; pt == nil || pt.Value != "Title" || len(mi.Warnings) == 0 {
+			t.Errorf("%s: TIT2 %+v, warnings %q", name, pt, mi.Warnings)
+		}
+	}
+}
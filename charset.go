@@ -0,0 +1,138 @@
+package id3v2
+
+import (
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+)
+
+// Options are the options of a read (see ProcessAllTagsWith).
+// Many old Windows taggers wrote the strings of the system codepage (eg,
+// CP1251, Shift-JIS, GBK) in ID3v1 tags and in ID3v2 frames declared as
+// ISO-8859-1. Charset, or DetectCharset, tells how to decode them.
+// Lenient sets the lenient mode of the read (see the Lenient variable).
+type Options struct {
+	Charset       encoding.Encoding // actual charset of the ISO-8859-1 strings, if not nil
+	DetectCharset bool              // guess the charset from the ISO-8859-1 strings of the file, if Charset is nil
+	Lenient       bool              // recover from the errors of broken tags
+}
+
+// Tell if b holds bytes out of the ASCII range.
+func hasHighBytes(b []byte) bool {
+	for _, c := range b {
+		if c >= 0x80 {
+			return true
+		}
+	}
+	return false
+}
+
+// Decode an ISO-8859-1 string that may actually be in another charset
+// (nil for ISO-8859-1).
+func decodeCharset(b []byte, cs encoding.Encoding) string {
+	if cs == nil || !hasHighBytes(b) {
+		return decodeISO8859(b)
+	}
+	d, err := cs.NewDecoder().Bytes(b)
+	if err != nil {
+		return decodeISO8859(b)
+	}
+	return string(d)
+}
+
+// Decode all the samples with a given charset, and return the decoded
+// non-ASCII runes, or nil if some sample is invalid in this charset.
+func decodeSamples(samples [][]byte, cs encoding.Encoding) []rune {
+	var r []rune
+	for _, s := range samples {
+		d, err := cs.NewDecoder().Bytes(s)
+		if err != nil {
+			return nil
+		}
+		for len(d) > 0 {
+			c, n := utf8.DecodeRune(d)
+			d = d[n:]
+			if c == utf8.RuneError {
+				return nil
+			}
+			if c >= 0x80 {
+				r = append(r, c)
+			}
+		}
+	}
+	return r
+}
+
+// Full-width kana (half-width katakana are not significant: they are what
+// most non-ASCII bytes decode to in Shift-JIS).
+var kana = &unicode.RangeTable{R16: []unicode.Range16{{Lo: 0x3040, Hi: 0x30ff, Stride: 1}}}
+
+// Return the share of the runes of r that belong to one of the tables.
+func share(r []rune, tables ...*unicode.RangeTable) float64 {
+	if len(r) == 0 {
+		return 0
+	}
+	n := 0
+	for _, c := range r {
+		if unicode.In(c, tables...) {
+			n++
+		}
+	}
+	return float64(n) / float64(len(r))
+}
+
+// DetectCharset guesses the charset of ISO-8859-1 strings from their bytes.
+// It returns nil if they look like actual ISO-8859-1 (or pure ASCII), and
+// otherwise one of Windows-1251, Shift-JIS, EUC-KR, GBK or Big5.
+//
+// The detection relies on simple statistics: accented Western letters are
+// mostly isolated among ASCII letters, while words of the other charsets
+// are runs of non-ASCII bytes; these runs must then be valid in the
+// multi-byte charsets, and decode to kana (Shift-JIS) or almost only to
+// Hangul (EUC-KR);
+// Cyrillic text is mostly made of lower-case letters ($E0-FF in CP1251).
+func DetectCharset(samples [][]byte) encoding.Encoding {
+	var high, paired, lower int
+	for _, s := range samples {
+		for i, c := range s {
+			if c < 0x80 {
+				continue
+			}
+			high++
+			if i > 0 && s[i-1] >= 0x80 || i+1 < len(s) && s[i+1] >= 0x80 {
+				paired++
+			}
+			if c >= 0xe0 {
+				lower++
+			}
+		}
+	}
+	if high == 0 || paired*2 < high { // Western text
+		return nil
+	}
+	if r := decodeSamples(samples, japanese.ShiftJIS); r != nil && share(r, kana) > 0.2 {
+		return japanese.ShiftJIS
+	}
+	if r := decodeSamples(samples, korean.EUCKR); r != nil && share(r, unicode.Hangul) > 0.9 {
+		return korean.EUCKR
+	}
+	if lower*10 >= high*6 { // mostly lower-case Cyrillic letters
+		return charmap.Windows1251
+	}
+	if r := decodeSamples(samples, simplifiedchinese.GBK); r != nil && share(r, unicode.Han) > 0.5 {
+		return simplifiedchinese.GBK
+	}
+	if r := decodeSamples(samples, traditionalchinese.Big5); r != nil && share(r, unicode.Han) > 0.5 {
+		return traditionalchinese.Big5
+	}
+	if r := decodeSamples(samples, charmap.Windows1251); share(r, unicode.Cyrillic) > 0.8 {
+		return charmap.Windows1251
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package id3v2
+
+import (
+	"bytes"
+	"testing"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+)
+
+// Encode strings in a charset.
+func encodeSamples(tb testing.TB, cs encoding.Encoding, s ...string) [][]byte {
+	var samples [][]byte
+	for _, v := range s {
+		b, err := cs.NewEncoder().Bytes([]byte(v))
+		if err != nil {
+			tb.Fatal(err)
+		}
+		samples = append(samples, b)
+	}
+	return samples
+}
+
+func TestDetectCharset(t *testing.T) {
+	for _, tc := range []struct {
+		cs   encoding.Encoding
+		text []string
+	}{
+		{charmap.ISO8859_1, []string{"Café Müller", "Sigur Rós", "Ágætis byrjun"}},
+		{charmap.Windows1251, []string{"Кино", "Группа крови", "Звезда по имени Солнце"}},
+		{japanese.ShiftJIS, []string{"ちいさな恋のうた", "モンゴル800", "メッセージ"}},
+		{korean.EUCKR, []string{"소녀시대", "다시 만난 세계"}},
+		{simplifiedchinese.GBK, []string{"周杰伦", "七里香", "我的地盘"}},
+		{traditionalchinese.Big5, []string{"周杰倫", "七里香", "藍色風暴"}},
+	} {
+		want := tc.cs
+		if want == charmap.ISO8859_1 {
+			want = nil
+		}
+		if cs := DetectCharset(encodeSamples(t, tc.cs, tc.text...)); cs != want {
+			t.Errorf("%q: %v, want %v", tc.text, cs, want)
+		}
+	}
+	if cs := DetectCharset([][]byte{[]byte("ASCII only")}); cs != nil {
+		t.Errorf("ASCII: %v", cs)
+	}
+}
+
+func TestReadCharset(t *testing.T) {
+	title, artist := "Группа крови", "Кино"
+	enc := encodeSamples(t, charmap.Windows1251, title, artist)
+	b := rawTag(3, "TIT2", "\x00"+string(enc[0]), "TPE2", "\x01\xff\xfeK\x00")
+	b = append(b, mpegFrame...)
+	b = append(b, v1Tag("", string(enc[1]), "", "", "", 0, 255)...)
+	for _, tc := range []struct {
+		name          string
+		opts          *Options
+		cs            encoding.Encoding // charset used
+		title, artist string
+	}{
+		{"ISO-8859-1", nil, nil, decodeISO8859(enc[0]), decodeISO8859(enc[1])},
+		{"Charset", &Options{Charset: charmap.Windows1251}, charmap.Windows1251, title, artist},
+		{"DetectCharset", &Options{DetectCharset: true}, charmap.Windows1251, title, artist},
+		{"Charset and DetectCharset", &Options{Charset: japanese.ShiftJIS, DetectCharset: true}, japanese.ShiftJIS, "", ""},
+	} {
+		mi, err := ReadAllTagsWith(bytes.NewReader(b), int64(len(b)), tc.opts)
+		if err != nil {
+			t.Fatalf("%s: %s", tc.name, err)
+		}
+		if mi.Charset != tc.cs {
+			t.Errorf("%s: charset %v", tc.name, mi.Charset)
+		}
+		if tc.title == "" { // the explicit charset wins, and decodes whatever it can
+			continue
+		}
+		if pt := mi.AllTags["TIT2"]; pt == nil || pt.Value != tc.title {
+			t.Errorf("%s: TIT2 %+v, want %q", tc.name, pt, tc.title)
+		}
+		if pt := mi.AllTags["TPE1"]; pt == nil || pt.Value != tc.artist || mi.ID3v1.Artist != tc.artist {
+			t.Errorf("%s: TPE1 %+v, ID3v1 artist %q, want %q", tc.name, pt, mi.ID3v1.Artist, tc.artist)
+		}
+		if pt := mi.AllTags["TPE2"]; pt == nil || pt.Value != "K" { // UTF-16 strings are not affected
+			t.Errorf("%s: TPE2 %+v", tc.name, pt)
+		}
+	}
+}
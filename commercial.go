@@ -0,0 +1,203 @@
+package id3v2
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Price is a price, as found in COMR and OWNE frames.
+type Price struct {
+	Currency string  // ISO 4217 currency code
+	Amount   float64 // amount in this currency
+}
+
+// Commercial is the decoded content of a COMR (commercial) frame.
+type Commercial struct {
+	Prices      []Price   // price of the item, in one or several currencies
+	ValidUntil  time.Time // date until which the prices are valid
+	ContactURL  string    // URL where the seller can be contacted
+	ReceivedAs  byte      // the way the audio was delivered (see ReceivedAsName)
+	Seller      string    // name of the seller
+	Description string    // short description of the product
+	LogoMIME    string    // MIME type of the seller's logo, if any
+	Logo        []byte    // seller's logo, if any
+}
+
+// Ownership is the decoded content of an OWNE (ownership) frame.
+type Ownership struct {
+	Prices    []Price   // price paid
+	Purchased time.Time // date of purchase
+	Seller    string    // name of the seller
+}
+
+// TermsOfUse is the decoded content of a USER (terms of use) frame.
+type TermsOfUse struct {
+	Language string // ISO-639-2 language code
+	Text     string
+}
+
+// Names of the "received as" values of COMR frames.
+var receivedAsNames = []string{
+	"Other",
+	"Standard CD album with other songs",
+	"Compressed audio on CD",
+	"File over the Internet",
+	"Stream over the Internet",
+	"As note sheets",
+	"As note sheets in a book with other sheets",
+	"Music on other media",
+	"Non-musical merchandise",
+}
+
+// ReceivedAsName returns a description of the way the audio was delivered.
+func (c *Commercial) ReceivedAsName() string {
+	if int(c.ReceivedAs) < len(receivedAsNames) {
+		return receivedAsNames[c.ReceivedAs]
+	}
+	return fmt.Sprintf("Unknown (0x%02x)", c.ReceivedAs)
+}
+
+// Parse a price string: one or several prices separated by '/', each one
+// made of a 3-letter currency code immediately followed by the amount, '.'
+// being the decimal separator (eg, "EUR9.99/USD10.99").
+func parsePrices(s string) ([]Price, error) {
+	var pp []Price
+	for _, p := range strings.Split(s, "/") {
+		if len(p) < 4 {
+			return nil, errors.New(fmt.Sprintf("Invalid price %q", p))
+		}
+		a, err := strconv.ParseFloat(p[3:], 64)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Invalid price %q", p))
+		}
+		pp = append(pp, Price{Currency: p[:3], Amount: a})
+	}
+	return pp, nil
+}
+
+// Format prices for display.
+func formatPrices(pp []Price) string {
+	s := make([]string, len(pp))
+	for i, p := range pp {
+		s[i] = fmt.Sprintf("%s %g", p.Currency, p.Amount)
+	}
+	return strings.Join(s, " / ")
+}
+
+// Parse an 8-character YYYYMMDD date.
+func parseDate8(b []byte) (time.Time, error) {
+	t, err := time.Parse("20060102", string(b))
+	if err != nil {
+		return time.Time{}, errors.New(fmt.Sprintf("Invalid date %q", b))
+	}
+	return t, nil
+}
+
+// Decode the payload of a COMR frame.
+//
+//	Text encoding     $xx
+//	Price string      <text string> $00
+//	Valid until       <text string> (YYYYMMDD)
+//	Contact URL       <text string> $00
+//	Received as       $xx
+//	Name of seller    <text string according to encoding> $00 (00)
+//	Description       <text string according to encoding> $00 (00)
+//	Picture MIME type <string> $00
+//	Seller logo       <binary data>
+//
+// As for text frames, an invalid string is decoded as well as possible: the
+// frame is returned with the error.
+func parseCOMR(pl []byte) (*Commercial, error) {
+	if len(pl) < 1 {
+		return nil, errors.New("Empty COMR frame")
+	}
+	et := pl[0]
+	price, rest, ok := splitString(0x00, pl[1:])
+	if !ok || len(rest) < 8 {
+		return nil, errors.New("Truncated COMR frame (price, valid until)")
+	}
+	var c Commercial
+	var err error
+	if c.Prices, err = parsePrices(string(price)); err != nil {
+		return nil, err
+	}
+	if c.ValidUntil, err = parseDate8(rest[:8]); err != nil {
+		return nil, err
+	}
+	url, rest, ok := splitString(0x00, rest[8:])
+	if !ok || len(rest) < 1 {
+		return nil, errors.New("Truncated COMR frame (contact URL, received as)")
+	}
+	c.ContactURL = decodeISO8859(url)
+	c.ReceivedAs = rest[0]
+	seller, rest, ok := splitString(et, rest[1:])
+	c.Seller, err = decodeText(et, seller)
+	if !ok {
+		return &c, err
+	}
+	desc, rest, ok := splitString(et, rest)
+	var derr error
+	if c.Description, derr = decodeText(et, desc); err == nil {
+		err = derr
+	}
+	if !ok {
+		return &c, err
+	}
+	mime, logo, ok := splitString(0x00, rest)
+	if ok && len(logo) > 0 {
+		c.LogoMIME = decodeISO8859(mime)
+		c.Logo = logo
+	}
+	return &c, err
+}
+
+// Decode the payload of an OWNE frame.
+//
+//	Text encoding     $xx
+//	Price paid        <text string> $00
+//	Date of purch.    <text string> (YYYYMMDD)
+//	Seller            <text string according to encoding>
+//
+// An invalid seller is decoded as well as possible: the frame is returned
+// with the error.
+func parseOWNE(pl []byte) (*Ownership, error) {
+	if len(pl) < 1 {
+		return nil, errors.New("Empty OWNE frame")
+	}
+	et := pl[0]
+	price, rest, ok := splitString(0x00, pl[1:])
+	if !ok || len(rest) < 8 {
+		return nil, errors.New("Truncated OWNE frame (price paid, date of purchase)")
+	}
+	var o Ownership
+	var err error
+	if o.Prices, err = parsePrices(string(price)); err != nil {
+		return nil, err
+	}
+	if o.Purchased, err = parseDate8(rest[:8]); err != nil {
+		return nil, err
+	}
+	seller, _, _ := splitString(et, rest[8:])
+	o.Seller, err = decodeText(et, seller)
+	return &o, err
+}
+
+// Decode the payload of a USER frame.
+//
+//	Text encoding     $xx
+//	Language          $xx xx xx
+//	The actual text   <text string according to encoding>
+//
+// An invalid text is decoded as well as possible: the frame is returned
+// with the error.
+func parseUSER(pl []byte) (*TermsOfUse, error) {
+	if len(pl) < 4 {
+		return nil, errors.New(fmt.Sprintf("USER frame too short (%d bytes)", len(pl)))
+	}
+	text, _, _ := splitString(pl[0], pl[4:])
+	s, err := decodeText(pl[0], text)
+	return &TermsOfUse{Language: string(pl[1:4]), Text: s}, err
+}
@@ -0,0 +1,65 @@
+package id3v2
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCommercialFrames(t *testing.T) {
+	comr := []byte("\x00EUR9.99/USD10.99\x0020251231https://shop.example\x00\x03Shop\x00Album download\x00image/png\x00\x89PNG")
+	owne := []byte("\x01USD0.99\x0020200102\xff\xfeS\x00h\x00\xf6\x00p\x00")
+	user := []byte("\x03engAll rights reserved ©")
+	mi := readFrames(t, 4, rawFrame("COMR", comr), rawFrame("OWNE", owne), rawFrame("USER", user))
+
+	c, ok := mi.AllTags["COMR"].Data.(*Commercial)
+	if !ok {
+		t.Fatalf("COMR frame %+v", mi.AllTags["COMR"])
+	}
+	want := &Commercial{
+		Prices:      []Price{{"EUR", 9.99}, {"USD", 10.99}},
+		ValidUntil:  time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC),
+		ContactURL:  "https://shop.example",
+		ReceivedAs:  3,
+		Seller:      "Shop",
+		Description: "Album download",
+		LogoMIME:    "image/png",
+		Logo:        []byte("\x89PNG"),
+	}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("COMR %+v, want %+v", c, want)
+	}
+	if c.ReceivedAsName() != "File over the Internet" {
+		t.Errorf("received as %q", c.ReceivedAsName())
+	}
+
+	o, ok := mi.AllTags["OWNE"].Data.(*Ownership)
+	if !ok || !reflect.DeepEqual(o, &Ownership{Prices: []Price{{"USD", 0.99}}, Purchased: time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC), Seller: "Shöp"}) {
+		t.Errorf("OWNE %+v", mi.AllTags["OWNE"])
+	}
+	u, ok := mi.AllTags["USER"].Data.(*TermsOfUse)
+	if !ok || u.Language != "eng" || u.Text != "All rights reserved ©" {
+		t.Errorf("USER %+v", mi.AllTags["USER"])
+	}
+}
+
+func TestCommercialFramesInvalid(t *testing.T) {
+	for _, tc := range []struct {
+		id      string
+		pl      string
+		partial bool // decoded, with an error
+	}{
+		{"COMR", "\x00EUR9.99", false},                 // truncated
+		{"COMR", "\x00EUR\x0020251231\x00\x00", false}, // invalid price
+		{"OWNE", "\x00USD1\x002020-1-2", false},        // invalid date
+		{"OWNE", "\x01USD1\x0020200102S\x00", true},    // missing BOM
+		{"USER", "\x00en", false},                      // too short
+		{"USER", "\x03engInvalid \xff UTF-8", true},    // invalid UTF-8
+	} {
+		mi := readFrames(t, 4, rawFrame(tc.id, []byte(tc.pl)))
+		pt := mi.AllTags[tc.id]
+		if pt.Err == nil || (pt.Data != nil) != tc.partial {
+			t.Errorf("%s %q: %+v", tc.id, tc.pl, pt)
+		}
+	}
+}
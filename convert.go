@@ -0,0 +1,449 @@
+package id3v2
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// ConvertTo converts the frames of a tag to another ID3v2 version, 3 or 4,
+// and returns the conversions that lose information, one per line.
+//
+// Upgrading an ID3v2.2 or ID3v2.3 tag to ID3v2.4 merges the TYER, TDAT and
+// TIME frames into a TDRC frame, and turns TORY into TDOR, IPLS into TIPL,
+// RVAD into RVA2 (as an approximation), and the UTF-16 strings of the frames
+// into UTF-8 strings. Downgrading an ID3v2.4 tag to ID3v2.3, for the players
+// that only know ID3v2.3, does the reverse: TDRC is split into TYER, TDAT
+// and TIME, TDOR becomes TORY, TIPL and TMCL are merged into IPLS, UTF-8
+// strings become UTF-16 ones and multiple values are joined with '/'.
+// The frames that have no equivalent in the target version are dropped.
+// The frames of the tag are replaced, not modified. If read-only frames
+// would be changed or dropped, the tag is left as is and an error is
+// returned, unless Force is set; these frames are listed in the Report of
+// the tag.
+func (t *Tag) ConvertTo(ver byte) ([]string, error) {
+	if ver != 3 && ver != 4 {
+		return nil, errors.New(fmt.Sprintf("Cannot convert to ID3v2.%d", ver))
+	}
+	if err := t.convertible(t.convert(ver)); err != nil {
+		return nil, err
+	}
+	t.alter()
+	c := t.convert(ver) // without the discarded frames
+	if ver < 4 {
+		t.Footer = false
+	}
+	t.Version, t.Frames = ver, c.frames
+	return c.lossy, nil
+}
+
+// Convert the frames of a tag to another version.
+func (t *Tag) convert(ver byte) *converter {
+	c := &converter{ver: ver}
+	if ver >= 4 {
+		c.upgrade(t.Frames)
+	} else {
+		c.downgrade(t.Frames)
+	}
+	return c
+}
+
+// Tell if the conversion of a tag may change or drop its read-only frames,
+// which it may not unless Force is set.
+func (t *Tag) convertible(c *converter) error {
+	kept := make(map[*ProcessedTag]bool)
+	for _, pt := range c.frames {
+		kept[pt] = true
+	}
+	var err error
+	for _, pt := range t.Frames {
+		if pt.Flags&flgRO == 0 || kept[pt] {
+			continue
+		}
+		if t.Force {
+			t.report(pt.ID, "read-only frame converted (forced)")
+			continue
+		}
+		t.report(pt.ID, "read-only frame kept")
+		if err == nil {
+			err = errors.New(fmt.Sprintf("Cannot convert the read-only %s frame to ID3v2.%d", pt.ID, c.ver))
+		}
+	}
+	return err
+}
+
+// A converter builds the frames of a tag converted to another version, and
+// records the lossy conversions.
+type converter struct {
+	ver    byte
+	frames []*ProcessedTag
+	lossy  []string
+}
+
+// Add a frame to the converted tag.
+func (c *converter) add(pt *ProcessedTag) {
+	c.frames = append(c.frames, pt)
+}
+
+// Record a lossy conversion of a frame.
+func (c *converter) loss(id, format string, a ...interface{}) {
+	c.lossy = append(c.lossy, id+": "+fmt.Sprintf(format, a...))
+}
+
+// Return a new text frame.
+func newTextFrame(id, name string, values ...string) *ProcessedTag {
+	return &ProcessedTag{Name: name, Value: strings.Join(values, "/"), ID: id, Values: values, Origin: OriginID3v2, edited: true}
+}
+
+// Upgrade frames to ID3v2.4.
+func (c *converter) upgrade(frames []*ProcessedTag) {
+	var year, date, time *ProcessedTag
+	for _, pt := range frames {
+		if pt.Encrypted() {
+			continue
+		}
+		switch pt.ID {
+		case "TYER":
+			year = pt
+		case "TDAT":
+			date = pt
+		case "TIME":
+			time = pt
+		}
+	}
+	for _, pt := range frames {
+		if len(pt.ID) != 4 { // unknown ID3v2.2 frame
+			c.loss(pt.ID, "dropped, ID3v2.2 frame with no ID3v2.3 equivalent")
+			continue
+		}
+		if pt.Encrypted() {
+			if v23Only[pt.ID] {
+				c.loss(pt.ID, "encrypted frame dropped")
+			} else {
+				c.add(pt)
+			}
+			continue
+		}
+		switch pt.ID {
+		case "TYER":
+			c.add(newTextFrame("TDRC", "Recorded on", c.recordingTime(year, date, time)))
+		case "TDAT", "TIME":
+			if year == nil {
+				c.loss(pt.ID, "dropped, without a TYER frame")
+			}
+		case "TORY":
+			c.add(newTextFrame("TDOR", "Original release time", textValues(pt)...))
+		case "IPLS":
+			c.add(newTextFrame("TIPL", "Involved people", pt.Values...))
+		case "RVAD":
+			pl, ok := rvadToRVA2(pt.Payload)
+			if !ok {
+				c.loss(pt.ID, "invalid frame dropped")
+				continue
+			}
+			c.add(&ProcessedTag{ID: "RVA2", Payload: pl, Origin: OriginID3v2})
+			c.loss(pt.ID, "volume adjustments approximated in RVA2")
+		case "EQUA", "TRDA", "TSIZ":
+			c.loss(pt.ID, "dropped, no ID3v2.4 equivalent")
+		default:
+			c.add(c.recode(pt))
+		}
+	}
+}
+
+// Downgrade frames to ID3v2.3.
+func (c *converter) downgrade(frames []*ProcessedTag) {
+	var ipls *ProcessedTag
+	for _, pt := range frames {
+		if len(pt.ID) != 4 { // unknown ID3v2.2 frame
+			c.loss(pt.ID, "dropped, ID3v2.2 frame with no ID3v2.3 equivalent")
+			continue
+		}
+		if pt.Encrypted() {
+			if v24Only[pt.ID] {
+				c.loss(pt.ID, "encrypted frame dropped")
+			} else {
+				c.add(pt)
+			}
+			continue
+		}
+		switch pt.ID {
+		case "TDRC":
+			c.splitRecordingTime(pt)
+		case "TDOR":
+			v := c.timestamp(pt, false)
+			if v != "" {
+				c.add(newTextFrame("TORY", "Original release year", v))
+			}
+		case "TIPL", "TMCL":
+			if ipls == nil {
+				ipls = newTextFrame("IPLS", "Involved people")
+				c.add(ipls)
+			}
+			if pt.ID == "TMCL" {
+				c.loss(pt.ID, "musician credits merged into IPLS")
+			}
+			ipls.Values = append(ipls.Values, textValues(pt)...)
+		case "TSOA", "TSOP", "TSOT": // not in ID3v2.3, but widely supported
+			c.add(c.recode(pt))
+		default:
+			if v24Only[pt.ID] {
+				c.loss(pt.ID, "dropped, no ID3v2.3 equivalent")
+				continue
+			}
+			if n := len(textValues(pt)); isTextFrame(pt) && (n > 2 || n > 1 && pt.ID != "TXXX") {
+				c.loss(pt.ID, "values joined with '/'")
+			}
+			c.add(c.recode(pt))
+		}
+	}
+	if ipls != nil { // IPLS is not a text frame: build its payload
+		ipls.Value = strings.Join(ipls.Values, "/")
+		et := textEncoding(3, ipls.Values...)
+		ipls.Payload = append([]byte{et}, encodeStrings(et, ipls.Values)...)
+	}
+}
+
+// Tell if s is made of n digits.
+func isDigits(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Build the value of a TDRC frame (yyyy-MM-ddTHH:mm) from the TYER, TDAT
+// (DDMM) and TIME (HHMM) frames.
+func (c *converter) recordingTime(year, date, time *ProcessedTag) string {
+	y := strings.Join(textValues(year), "/")
+	d, t := "", ""
+	if date != nil {
+		d = strings.Join(textValues(date), "/")
+	}
+	if time != nil {
+		t = strings.Join(textValues(time), "/")
+	}
+	switch {
+	case !isDigits(y, 4):
+		if d != "" || t != "" {
+			c.loss("TYER", "date and time dropped, with an invalid year %q", y)
+		}
+		return y
+	case d == "":
+	case !isDigits(d, 4):
+		c.loss("TDAT", "invalid date %q dropped", d)
+		d = ""
+	default:
+		y += "-" + d[2:] + "-" + d[:2]
+	}
+	switch {
+	case t == "":
+	case d == "":
+		c.loss("TIME", "dropped, without a date")
+	case !isDigits(t, 4):
+		c.loss("TIME", "invalid time %q dropped", t)
+	default:
+		y += "T" + t[:2] + ":" + t[2:]
+	}
+	return y
+}
+
+// Return the year of an ID3v2.4 timestamp frame (yyyy[-MM[-dd[THH[:mm[:ss]]]]]),
+// followed by its date and time (DDMM and HHMM) if asked, reporting what
+// is lost.
+func (c *converter) timestamp(pt *ProcessedTag, full bool) string {
+	values := textValues(pt)
+	if len(values) == 0 || len(values[0]) < 4 || !isDigits(values[0][:4], 4) {
+		c.loss(pt.ID, "invalid timestamp %q dropped", strings.Join(values, "/"))
+		return ""
+	}
+	s := values[0]
+	if len(values) > 1 {
+		c.loss(pt.ID, "only the first timestamp kept")
+	}
+	v, kept := s[:4], s[:4]
+	if full && len(s) >= 10 && s[4] == '-' && s[7] == '-' && isDigits(s[5:7]+s[8:10], 4) {
+		v += s[8:10] + s[5:7]
+		kept = s[:10]
+		if len(s) >= 16 && s[10] == 'T' && s[13] == ':' && isDigits(s[11:13]+s[14:16], 4) {
+			v += s[11:13] + s[14:16]
+			kept = s[:16]
+		}
+	}
+	if kept != s {
+		c.loss(pt.ID, "%q truncated to %q", s, kept)
+	}
+	return v
+}
+
+// Split a TDRC frame into TYER, TDAT and TIME frames.
+func (c *converter) splitRecordingTime(pt *ProcessedTag) {
+	v := c.timestamp(pt, true)
+	if v == "" {
+		return
+	}
+	c.add(newTextFrame("TYER", "Year", v[:4]))
+	if len(v) >= 8 {
+		c.add(newTextFrame("TDAT", "Date", v[4:8]))
+	}
+	if len(v) >= 12 {
+		c.add(newTextFrame("TIME", "Time", v[8:12]))
+	}
+}
+
+// The layout of the frames, other than text frames, holding strings in a
+// text encoding: e is the encoding byte, l a terminated ISO-8859-1 string,
+// s a terminated string in the encoding, S a string in the encoding up to
+// the end of the frame, and a digit a number of bytes. The rest of the
+// payload, if any, is kept as is.
+var stringFrames = map[string]string{
+	"APIC": "el1s",
+	"COMM": "e3sS",
+	"GEOB": "elss",
+	"USER": "e3S",
+	"USLT": "e3sS",
+	"WXXX": "es",
+}
+
+// Return a frame whose strings are encoded for the target version: UTF-8
+// rather than UTF-16 in ID3v2.4, and ISO-8859-1 or UTF-16 with a BOM in
+// ID3v2.3. The frame is returned as is if it needs no conversion.
+func (c *converter) recode(pt *ProcessedTag) *ProcessedTag {
+	if isTextFrame(pt) {
+		return c.recodeText(pt)
+	}
+	layout, ok := stringFrames[pt.ID]
+	if !ok || len(pt.Payload) == 0 {
+		return pt
+	}
+	et := pt.Payload[0]
+	if c.ver >= 4 && et != 0x01 && et != 0x02 || c.ver < 4 && et != 0x02 && et != 0x03 {
+		return pt
+	}
+
+	// Decode the strings, then encode them again.
+	type field struct {
+		raw  []byte
+		str  string
+		term bool
+	}
+	var fields []field
+	var strs []string
+	pl := pt.Payload
+	for _, k := range layout {
+		switch {
+		case k == 'e':
+			pl = pl[1:]
+		case k >= '0' && k <= '9':
+			n := int(k - '0')
+			if len(pl) < n {
+				return pt
+			}
+			fields = append(fields, field{raw: pl[:n]})
+			pl = pl[n:]
+		case k == 'l':
+			s, rest, ok := splitString(0x00, pl)
+			if !ok {
+				return pt
+			}
+			fields = append(fields, field{raw: append(append([]byte{}, s...), 0x00)})
+			pl = rest
+		default:
+			s, rest, ok := splitString(et, pl)
+			if k == 'S' { // up to the end, or a final terminator
+				rest = nil
+			} else if !ok {
+				return pt
+			}
+			str, err := decodeText(et, s)
+			if err != nil {
+				c.loss(pt.ID, "invalid string %q", str)
+			}
+			fields = append(fields, field{str: str, term: k == 's'})
+			strs = append(strs, str)
+			pl = rest
+		}
+	}
+	net := textEncoding(c.ver, strs...)
+	b := []byte{net}
+	for _, f := range fields {
+		if f.raw != nil {
+			b = append(b, f.raw...)
+			continue
+		}
+		b = append(b, encodeString(net, f.str)...)
+		if f.term {
+			b = append(b, terminator(net)...)
+		}
+	}
+	npt := *pt
+	npt.Payload = append(b, pl...)
+	return &npt
+}
+
+// Return a text frame whose payload is to be built again from its values
+// if its text encoding, or its multiple values in ID3v2.3, do not suit the
+// target version. The frame is returned as is otherwise.
+func (c *converter) recodeText(pt *ProcessedTag) *ProcessedTag {
+	if len(pt.Payload) == 0 {
+		return pt
+	}
+	et, n := pt.Payload[0], len(textValues(pt))
+	if c.ver >= 4 && et != 0x01 && et != 0x02 || c.ver < 4 && et != 0x02 && et != 0x03 && (n < 2 || n == 2 && pt.ID == "TXXX") {
+		return pt
+	}
+	npt := *pt
+	npt.edited = true
+	return &npt
+}
+
+// Convert the payload of an RVAD frame to the payload of an RVA2 frame.
+// The volume changes of RVAD, relative to the full scale of their number of
+// bits, are converted to adjustments in dB, within the +/-64 dB of RVA2.
+func rvadToRVA2(pl []byte) ([]byte, bool) {
+	if len(pl) < 2 || pl[1] == 0 {
+		return nil, false
+	}
+	inc, bits := pl[0], int(pl[1])
+	n := (bits + 7) / 8
+	pl = pl[2:]
+	full := math.Pow(2, float64(bits)) - 1
+
+	// The channels come in groups: front right and left, back right and
+	// left, centre, and bass. Each group holds its volume changes, then its
+	// peaks.
+	groups := [][]byte{{0x02, 0x03}, {0x04, 0x05}, {0x06}, {0x08}}
+	b := []byte{0x00} // empty identification
+	bit := uint(0)
+	for _, g := range groups {
+		if len(pl) < 2*n*len(g) {
+			break
+		}
+		for i, ch := range g {
+			ratio := 0.0
+			for _, x := range pl[i*n : (i+1)*n] {
+				ratio = ratio*256 + float64(x)
+			}
+			ratio /= full
+			if inc&(1<<bit) == 0 {
+				ratio = -ratio
+			}
+			bit++
+			db := -64.0
+			if ratio > -1 {
+				db = math.Max(-64, math.Min(20*math.Log10(1+ratio), 32767.0/512))
+			}
+			adj := int16(math.Round(db * 512))
+			b = append(b, ch, byte(uint16(adj)>>8), byte(adj), byte(bits))
+			b = append(b, pl[(len(g)+i)*n:(len(g)+i+1)*n]...)
+		}
+		pl = pl[2*n*len(g):]
+	}
+	return b, len(b) > 1
+}
@@ -0,0 +1,44 @@
+package id3v2
+
+import (
+	"testing"
+)
+
+func TestConvertReadOnly(t *testing.T) {
+	newTag := func() *Tag {
+		year := newTextFrame("TYER", "Year", "2001")
+		year.Flags = flgRO
+		comm := &ProcessedTag{Name: "Comment", ID: "COMM", Payload: langTextPayload(3, "eng", "", "Коммент"), Flags: flgRO, Origin: OriginID3v2}
+		return &Tag{Version: 3, Frames: []*ProcessedTag{newTextFrame("TIT2", "Title", "Title"), year, comm}}
+	}
+
+	// The read-only frames cannot be converted: the tag is left as is.
+	tag := newTag()
+	frames := append([]*ProcessedTag{}, tag.Frames...)
+	if _, err := tag.ConvertTo(4); err == nil {
+		t.Fatal("read-only frames converted")
+	}
+	if tag.Version != 3 || len(tag.Frames) != len(frames) || len(tag.Report) != 2 {
+		t.Fatalf("version %d, %d frames, report %q", tag.Version, len(tag.Frames), tag.Report)
+	}
+	for i, pt := range tag.Frames {
+		if pt != frames[i] || pt.ID != "TIT2" && pt.Flags&flgRO == 0 {
+			t.Errorf("frame %d changed: %+v", i, pt)
+		}
+	}
+
+	// They can be when forced, and keep their flag if they are not dropped.
+	tag = newTag()
+	tag.Force = true
+	if _, err := tag.ConvertTo(4); err != nil {
+		t.Fatal(err)
+	}
+	if tag.Version != 4 || len(tag.Report) != 2 {
+		t.Fatalf("version %d, report %q", tag.Version, tag.Report)
+	}
+	for _, pt := range tag.Frames {
+		if pt.ID == "COMM" && (pt.Flags&flgRO == 0 || pt.Payload[0] != 0x03) {
+			t.Errorf("COMM frame: flags 0x%04x, encoding 0x%02x", pt.Flags, pt.Payload[0])
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package id3v2
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Replace the frames of a tag with a given ID by a frame, where the first
+// one was, or add the frame if there was none. If frame is nil, the frames
+// are removed.
+func (t *Tag) setFrame(id string, frame *ProcessedTag) {
+	t.replaceFrames(MatchID(id), frame)
+}
+
+// Replace the frames of a tag for which match returns true by a frame, where
+// the first one was, or add the frame if there was none. If frame is nil,
+// the frames are removed. Nothing is done if one of the frames is read-only,
+// unless Force is set.
+func (t *Tag) replaceFrames(match func(pt *ProcessedTag) bool, frame *ProcessedTag) {
+	t.alter()
+	if !t.editable(match) {
+		return
+	}
+	if frame != nil {
+		frame.edited = true
+		for i, pt := range t.Frames {
+			if match(pt) {
+				t.Frames[i] = frame
+				t.removeFrames(func(pt *ProcessedTag) bool { return pt != frame && match(pt) })
+				return
+			}
+		}
+		t.Frames = append(t.Frames, frame)
+		return
+	}
+	t.removeFrames(match)
+}
+
+// Set the values of a text frame, or remove it if there is no value or the
+// only value is empty.
+func (t *Tag) setText(id, name string, values ...string) {
+	if len(values) == 0 || len(values) == 1 && values[0] == "" {
+		t.setFrame(id, nil)
+		return
+	}
+	t.setFrame(id, newTextFrame(id, name, values...))
+}
+
+// SetTitle sets the title (TIT2 frame). An empty title removes the frame, as
+// do the empty values of the other setters.
+func (t *Tag) SetTitle(title string) {
+	t.setText("TIT2", "Title", title)
+}
+
+// SetArtist sets the artists (TPE1 frame). Several artists are written as
+// multiple values in ID3v2.4 tags, and joined with '/' in ID3v2.3 tags.
+func (t *Tag) SetArtist(artists ...string) {
+	t.setText("TPE1", "Artist(s)", artists...)
+}
+
+// SetAlbumArtist sets the album artist (TPE2 frame).
+func (t *Tag) SetAlbumArtist(artist string) {
+	t.setText("TPE2", "Band", artist)
+}
+
+// SetAlbum sets the album title (TALB frame).
+func (t *Tag) SetAlbum(album string) {
+	t.setText("TALB", "Album", album)
+}
+
+// Format a position in a set, as "n" or "n/total".
+func position(n, total int) string {
+	switch {
+	case n <= 0:
+		return ""
+	case total <= 0:
+		return strconv.Itoa(n)
+	}
+	return fmt.Sprintf("%d/%d", n, total)
+}
+
+// SetTrack sets the track number, and the number of tracks of the album
+// unless total is 0 (TRCK frame). A track number of 0 removes the frame.
+func (t *Tag) SetTrack(n, total int) {
+	t.setText("TRCK", "Track", position(n, total))
+}
+
+// SetDisc sets the disc number, and the number of discs of the set unless
+// total is 0 (TPOS frame). A disc number of 0 removes the frame.
+func (t *Tag) SetDisc(n, total int) {
+	t.setText("TPOS", "Part", position(n, total))
+}
+
+// SetGenre sets the genre (TCON frame). In ID3v2.3 tags, the ID3v1 index of
+// a known genre is written before its name, as in "(17)Rock".
+func (t *Tag) SetGenre(genre string) {
+	if i := genreIndex(genre); t.Version < 4 && i != 255 && genre != "" {
+		genre = fmt.Sprintf("(%d)%s", i, v1Genres[i])
+	}
+	t.setText("TCON", "Content type", genre)
+}
+
+// SetYear sets the recording year: TDRC frame in ID3v2.4 tags, TYER frame
+// in ID3v2.3 tags (the TDAT and TIME frames are removed). A year of 0
+// removes the frames.
+func (t *Tag) SetYear(year int) {
+	y := ""
+	if year > 0 {
+		y = fmt.Sprintf("%04d", year)
+	}
+	if t.Version >= 4 {
+		t.setText("TDRC", "Recorded on", y)
+		return
+	}
+	t.setText("TYER", "Year", y)
+	t.setText("TDAT", "Date")
+	t.setText("TIME", "Time")
+}
+
+// SetDate sets the recording date, and time unless it is midnight: TDRC
+// frame in ID3v2.4 tags, TYER, TDAT and TIME frames in ID3v2.3 tags (which
+// have no seconds). A zero date removes the frames.
+func (t *Tag) SetDate(d time.Time) {
+	if d.IsZero() {
+		t.SetYear(0)
+		return
+	}
+	withTime := d.Hour() != 0 || d.Minute() != 0 || d.Second() != 0
+	if t.Version >= 4 {
+		ts := d.Format("2006-01-02")
+		if withTime {
+			ts = d.Format("2006-01-02T15:04:05")
+		}
+		t.setText("TDRC", "Recorded on", ts)
+		return
+	}
+	t.setText("TYER", "Year", d.Format("2006"))
+	t.setText("TDAT", "Date", d.Format("0201"))
+	if withTime {
+		t.setText("TIME", "Time", d.Format("1504"))
+	} else {
+		t.setText("TIME", "Time")
+	}
+}
+
+// Build the payload of a frame made of a language, a description and a
+// text (COMM and USLT frames).
+func langTextPayload(ver byte, lang, desc, text string) []byte {
+	//	Text encoding   $xx
+	//	Language        $xx xx xx
+	//	Description     <text string according to encoding> $00 (00)
+	//	Text            <full text string according to encoding>
+	et := textEncoding(ver, desc, text)
+	pl := append([]byte{et}, langCode(lang)...)
+	pl = append(pl, encodeStrings(et, []string{desc, text})...)
+	return pl
+}
+
+// Return the language code a language is written as: "XXX" (unknown
+// language) if it is not a 3-character code.
+func langCode(lang string) string {
+	if len(lang) != 3 {
+		return "XXX"
+	}
+	return lang
+}
+
+// Return a function that matches the frames of an ID, made of a language
+// and a description (COMM and USLT frames), with a given language and
+// description.
+func matchLangDesc(id, lang, desc string) func(pt *ProcessedTag) bool {
+	lang = langCode(lang)
+	return func(pt *ProcessedTag) bool {
+		if pt.ID != id || pt.Encrypted() || len(pt.Payload) < 4 {
+			return false
+		}
+		d, _, ok := parseCOMM(pt.Payload)
+		return ok && d == desc && string(pt.Payload[1:4]) == lang
+	}
+}
+
+// AddComment adds a comment (COMM frame) in a language (an ISO-639-2 code,
+// eg "eng"), with a short description that may be empty. It replaces the
+// comment with the same language and description, if any; an empty text
+// removes it.
+func (t *Tag) AddComment(lang, desc, text string) {
+	var frame *ProcessedTag
+	if text != "" {
+		frame = &ProcessedTag{Name: "Comment", Value: text, ID: "COMM", Payload: langTextPayload(t.Version, lang, desc, text), Origin: OriginID3v2}
+	}
+	t.replaceFrames(matchLangDesc("COMM", lang, desc), frame)
+}
+
+// SetLyrics sets the unsynchronised lyrics (USLT frame without description)
+// in a language (an ISO-639-2 code, eg "eng"). Empty lyrics remove the
+// frame.
+func (t *Tag) SetLyrics(lang, lyrics string) {
+	var frame *ProcessedTag
+	if lyrics != "" {
+		frame = &ProcessedTag{Name: "Lyrics", Value: lyrics, ID: "USLT", Payload: langTextPayload(t.Version, lang, "", lyrics), Origin: OriginID3v2}
+	}
+	t.replaceFrames(matchLangDesc("USLT", lang, ""), frame)
+}
+
+// SetRating sets the rating of the file by a user (POPM frame), from 1
+// (worst) to 255 (best), keeping the play counter of the user. A rating of
+// 0 removes the frame. The email is an ISO-8859-1 string: its other
+// characters are written as '?'.
+func (t *Tag) SetRating(email string, rating byte) {
+	email = decodeISO8859(encodeString(0x00, email)) // as it is read back
+	match := MatchPOPM(email)
+	if rating == 0 {
+		t.RemoveFrames(match)
+		return
+	}
+	p := &Popularimeter{Email: email, Rating: rating}
+	for _, pt := range t.Frames {
+		if match(pt) {
+			p.Counter = pt.Data.(*Popularimeter).Counter
+			break
+		}
+	}
+	frame := &ProcessedTag{Name: "Popularimeter", Value: p.String(), Data: p, ID: "POPM", Payload: p.payload(), Origin: OriginID3v2}
+	t.replaceFrames(match, frame)
+}
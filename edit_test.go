@@ -0,0 +1,57 @@
+package id3v2
+
+import (
+	"bytes"
+	"testing"
+)
+
+// Encode a tag and read it back.
+func reload(t *testing.T, tag *Tag) *Tag {
+	b, err := tag.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mi, err := ReadAllTags(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mi.Tag()
+}
+
+func TestAddCommentReplaces(t *testing.T) {
+	tag := &Tag{Version: 3}
+	tag.AddComment("", "", "x1")
+	tag = reload(t, tag)
+	tag.AddComment("", "", "x2")
+	tag.AddComment("eng", "", "x3")
+	var texts []string
+	for _, pt := range tag.Frames {
+		if pt.ID == "COMM" {
+			texts = append(texts, pt.Value)
+		}
+	}
+	if len(texts) != 2 || texts[0] != "x2" || texts[1] != "x3" {
+		t.Errorf("comments %q", texts)
+	}
+}
+
+func TestSetRatingEmail(t *testing.T) {
+	for _, email := range []string{"me@example.com", "é@example.com", "я@example.com"} {
+		tag := &Tag{Version: 4}
+		tag.SetRating(email, 200)
+		tag = reload(t, tag)
+		tag.SetRating(email, 100) // replaces the rating
+		n := 0
+		for _, pt := range tag.Frames {
+			if p, ok := pt.Data.(*Popularimeter); ok {
+				n++
+				if p.Rating != 100 {
+					t.Errorf("%s: rating %d", email, p.Rating)
+				}
+			}
+		}
+		if n != 1 {
+			t.Errorf("%s: %d POPM frames", email, n)
+		}
+	}
+}
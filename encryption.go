@@ -0,0 +1,121 @@
+package id3v2
+
+import (
+	"errors"
+	"fmt"
+)
+
+// AudioEncryption is the decoded content of an AENC (audio encryption) frame.
+type AudioEncryption struct {
+	Owner         string // owner identifier (usually an URL or an email)
+	PreviewStart  uint16 // first unencrypted MPEG frame
+	PreviewLength uint16 // count of unencrypted MPEG frames
+	Info          []byte // encryption info
+}
+
+// EncryptionMethod is the decoded content of an ENCR (encryption method
+// registration) frame. Frames encrypted with this method carry its symbol.
+type EncryptionMethod struct {
+	Owner  string // owner identifier
+	Method byte   // method symbol
+	Data   []byte // encryption data
+}
+
+// GroupRegistration is the decoded content of a GRID (group identification
+// registration) frame. Frames belonging to this group carry its symbol.
+type GroupRegistration struct {
+	Owner  string // owner identifier
+	Symbol byte   // group symbol
+	Data   []byte // group dependent data
+}
+
+// Split the owner identifier that starts most of the registration frames.
+func splitOwner(pl []byte, id string) (string, []byte, error) {
+	owner, rest, ok := splitString(0x00, pl)
+	if !ok {
+		return "", nil, errors.New(fmt.Sprintf("Missing 0x00 after owner identifier in %s frame", id))
+	}
+	return decodeISO8859(owner), rest, nil
+}
+
+// Decode the payload of an AENC frame.
+//
+//	Owner identifier   <text string> $00
+//	Preview start      $xx xx
+//	Preview length     $xx xx
+//	Encryption info    <binary data>
+func parseAENC(pl []byte) (*AudioEncryption, error) {
+	owner, rest, err := splitOwner(pl, "AENC")
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) < 4 {
+		return nil, errors.New("Truncated AENC frame (preview start, preview length)")
+	}
+	return &AudioEncryption{
+		Owner:         owner,
+		PreviewStart:  uint16(rest[0])<<8 | uint16(rest[1]),
+		PreviewLength: uint16(rest[2])<<8 | uint16(rest[3]),
+		Info:          rest[4:],
+	}, nil
+}
+
+// Decode the payload of an ENCR frame.
+//
+//	Owner identifier   <text string> $00
+//	Method symbol      $xx
+//	Encryption data    <binary data>
+func parseENCR(pl []byte) (*EncryptionMethod, error) {
+	owner, rest, err := splitOwner(pl, "ENCR")
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) < 1 {
+		return nil, errors.New("Truncated ENCR frame (method symbol)")
+	}
+	return &EncryptionMethod{Owner: owner, Method: rest[0], Data: rest[1:]}, nil
+}
+
+// Decode the payload of a GRID frame.
+//
+//	Owner identifier      <text string> $00
+//	Group symbol          $xx
+//	Group dependent data  <binary data>
+func parseGRID(pl []byte) (*GroupRegistration, error) {
+	owner, rest, err := splitOwner(pl, "GRID")
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) < 1 {
+		return nil, errors.New("Truncated GRID frame (group symbol)")
+	}
+	return &GroupRegistration{Owner: owner, Symbol: rest[0], Data: rest[1:]}, nil
+}
+
+// Link the encrypted and grouped tags to the ENCR and GRID registrations of
+// their method and group symbols.
+func linkRegistrations(frames []*ProcessedTag) {
+	encr := make(map[byte]*EncryptionMethod)
+	grid := make(map[byte]*GroupRegistration)
+	for _, pt := range frames {
+		switch d := pt.Data.(type) {
+		case *EncryptionMethod:
+			encr[d.Method] = d
+		case *GroupRegistration:
+			grid[d.Symbol] = d
+		}
+	}
+	for _, pt := range frames {
+		if pt.Encrypted() {
+			pt.Encryption = encr[pt.EncMethod]
+			if pt.Encryption != nil {
+				pt.Value += fmt.Sprintf(" (%q)", pt.Encryption.Owner)
+			} else {
+				pt.Value += " (unregistered method)"
+			}
+		}
+		if pt.Grouped() {
+			pt.Group = grid[pt.GroupID]
+		}
+	}
+}
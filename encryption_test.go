@@ -0,0 +1,42 @@
+package id3v2
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRegistrations(t *testing.T) {
+	for _, ver := range []byte{3, 4} {
+		title := newTextFrame("TIT2", "Title", "Grouped title")
+		title.Flags, title.GroupID = flgGroupId, 0x81
+		mi := readFrames(t, ver,
+			rawFrame("AENC", []byte("https://owner.example\x00\x00\x10\x00\x20info")),
+			&ProcessedTag{ID: "TALB", Payload: []byte("secret"), Flags: flgEncrypted, EncMethod: 0x80, Origin: OriginID3v2},
+			&ProcessedTag{ID: "TPE1", Payload: []byte("other"), Flags: flgEncrypted, EncMethod: 0x90, Origin: OriginID3v2},
+			title,
+			rawFrame("ENCR", []byte("mailto:crypt@example.com\x00\x80key")),
+			rawFrame("GRID", []byte("https://group.example\x00\x81data")),
+		)
+
+		a, ok := mi.AllTags["AENC"].Data.(*AudioEncryption)
+		if !ok || a.Owner != "https://owner.example" || a.PreviewStart != 16 || a.PreviewLength != 32 || string(a.Info) != "info" {
+			t.Errorf("ID3v2.%d: AENC %+v", ver, mi.AllTags["AENC"])
+		}
+
+		// The registrations follow the frames that refer to them.
+		alb := mi.AllTags["TALB"]
+		if !alb.Encrypted() || alb.Encryption == nil || alb.Encryption.Owner != "mailto:crypt@example.com" || alb.Encryption.Method != 0x80 || string(alb.Encryption.Data) != "key" {
+			t.Errorf("ID3v2.%d: TALB %+v, encryption %+v", ver, alb, alb.Encryption)
+		}
+		if !bytes.Equal(alb.Payload, []byte("secret")) {
+			t.Errorf("ID3v2.%d: TALB payload %q", ver, alb.Payload)
+		}
+		if pe := mi.AllTags["TPE1"]; pe.Encryption != nil || pe.Value != "5 bytes, method 0x90 (unregistered method)" {
+			t.Errorf("ID3v2.%d: TPE1 %+v", ver, pe)
+		}
+		tit := mi.AllTags["TIT2"]
+		if !tit.Grouped() || tit.Group == nil || tit.Group.Owner != "https://group.example" || string(tit.Group.Data) != "data" || tit.Value != "Grouped title" {
+			t.Errorf("ID3v2.%d: TIT2 %+v, group %+v", ver, tit, tit.Group)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package id3v2
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"strings"
+)
+
+const (
+	// ID3v2.3 extended header flags
+	xflgCRC23 = 0x8000 // CRC data present
+
+	// ID3v2.4 extended header flags
+	xflgUpdate       = 0x40 // b: Tag is an update
+	xflgCRC24        = 0x20 // c: CRC data present
+	xflgRestrictions = 0x10 // d: Tag restrictions
+)
+
+// ExtendedHeader is the decoded extended header of an ID3v2.3 or ID3v2.4
+// tag.
+type ExtendedHeader struct {
+	Size         uint32           // size of the extended header, in the file
+	Update       bool             // the tag is an update of a previous tag (ID3v2.4)
+	HasCRC       bool             // the header holds a CRC-32 of the tag
+	CRC          uint32           // CRC-32 of the tag, if HasCRC
+	CRCValid     bool             // the CRC matches the tag data
+	Padding      uint32           // size of the padding (ID3v2.3)
+	Restrictions *TagRestrictions // tag restrictions (ID3v2.4), or nil
+}
+
+// TagRestrictions are the restrictions an ID3v2.4 tag was written with.
+// Each field holds the value of the corresponding bits of the restrictions
+// byte (%ppqrrstt).
+type TagRestrictions struct {
+	TagSize       byte // pp: 0: 128 frames, 1 MB; 1: 64 frames, 128 KB; 2: 32 frames, 40 KB; 3: 32 frames, 4 KB
+	TextEncoding  byte // q: 1: ISO-8859-1 or UTF-8 only
+	TextSize      byte // rr: 0: no limit; 1: 1024 chars; 2: 128 chars; 3: 30 chars
+	ImageEncoding byte // s: 1: PNG or JPEG only
+	ImageSize     byte // tt: 0: no limit; 1: 256x256 max; 2: 64x64 max; 3: 64x64 exactly
+}
+
+var (
+	restrTagFrames = [4]int{128, 64, 32, 32}
+	restrTagBytes  = [4]int{1 << 20, 128 << 10, 40 << 10, 4 << 10}
+	restrTextSize  = [4]int{0, 1024, 128, 30}
+	restrImageSize = [4]string{"", "256x256 max", "64x64 max", "64x64"}
+)
+
+// Decode a restrictions byte.
+func parseRestrictions(b byte) *TagRestrictions {
+	return &TagRestrictions{
+		TagSize:       b >> 6,
+		TextEncoding:  b >> 5 & 1,
+		TextSize:      b >> 3 & 3,
+		ImageEncoding: b >> 2 & 1,
+		ImageSize:     b & 3,
+	}
+}
+
+// MaxFrames returns the maximum count of frames of the tag.
+func (r *TagRestrictions) MaxFrames() int {
+	return restrTagFrames[r.TagSize&3]
+}
+
+// MaxSize returns the maximum size of the tag, in bytes.
+func (r *TagRestrictions) MaxSize() int {
+	return restrTagBytes[r.TagSize&3]
+}
+
+// MaxTextSize returns the maximum count of characters of a text field, or 0
+// if there is no limit.
+func (r *TagRestrictions) MaxTextSize() int {
+	return restrTextSize[r.TextSize&3]
+}
+
+// String returns a description of the restrictions.
+func (r *TagRestrictions) String() string {
+	s := []string{fmt.Sprintf("%d frames, %d bytes", r.MaxFrames(), r.MaxSize())}
+	if r.TextEncoding != 0 {
+		s = append(s, "ISO-8859-1 or UTF-8 text")
+	}
+	if n := r.MaxTextSize(); n != 0 {
+		s = append(s, fmt.Sprintf("%d chars per text", n))
+	}
+	if r.ImageEncoding != 0 {
+		s = append(s, "PNG or JPEG images")
+	}
+	if r.ImageSize != 0 {
+		s = append(s, restrImageSize[r.ImageSize&3]+" images")
+	}
+	return strings.Join(s, ", ")
+}
+
+// Return a 28-bit syncsafe integer (4 bytes of 7 bits).
+func syncsafe(b []byte) uint {
+	return uint(b[0])<<21 | uint(b[1])<<14 | uint(b[2])<<7 | uint(b[3])
+}
+
+// Tell if b is made of syncsafe bytes.
+func isSyncsafe(b []byte) bool {
+	for _, c := range b {
+		if c&0x80 != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Undo the unsynchronisation of b: every $FF 00 becomes $FF.
+func deunsync(b []byte) []byte {
+	d := make([]byte, 0, len(b))
+	for i := 0; i < len(b); i++ {
+		d = append(d, b[i])
+		if b[i] == 0xff && i+1 < len(b) && b[i+1] == 0x00 {
+			i++
+		}
+	}
+	return d
+}
+
+// Decode the extended header at the beginning of hb, the tag without its
+// header, and verify the CRC of the tag, if any. This returns the extended
+// header and its size in hb (the offset of the first frame).
+func parseExtHeader(ver byte, hb []byte) (*ExtendedHeader, uint, error) {
+	if ver >= 4 {
+		return parseExtHeader24(hb)
+	}
+	return parseExtHeader23(hb)
+}
+
+// Decode an ID3v2.3 extended header.
+//
+//	Extended header size   $xx xx xx xx (6 or 10, not including itself)
+//	Extended Flags         $xx xx (%x0000000 00000000, x: CRC data present)
+//	Size of padding        $xx xx xx xx
+//	Total frame CRC        $xx xx xx xx (if x is set)
+//
+// The CRC covers the frames only, between the extended header and the
+// padding.
+func parseExtHeader23(hb []byte) (*ExtendedHeader, uint, error) {
+	if len(hb) < 10 {
+		return nil, 0, errors.New("Truncated extended header")
+	}
+	xsiz := uint(hb[0])<<24 | uint(hb[1])<<16 | uint(hb[2])<<8 | uint(hb[3])
+	if xsiz != 6 && xsiz != 10 {
+		return nil, 0, errors.New(fmt.Sprintf("Invalid extended header size (%d)", xsiz))
+	}
+	xflg := uint16(hb[4])<<8 | uint16(hb[5])
+	if xflg&^xflgCRC23 != 0 {
+		return nil, 0, errors.New(fmt.Sprintf("Invalid flags for extended tag (0x%x)", xflg))
+	}
+	xh := &ExtendedHeader{
+		Size:    uint32(4 + xsiz),
+		Padding: uint32(hb[6])<<24 | uint32(hb[7])<<16 | uint32(hb[8])<<8 | uint32(hb[9]),
+	}
+	if xflg&xflgCRC23 != 0 {
+		if xsiz < 10 || len(hb) < 14 {
+			return nil, 0, errors.New(fmt.Sprintf("Invalid extended header size (%d) for a CRC", xsiz))
+		}
+		xh.HasCRC = true
+		xh.CRC = uint32(hb[10])<<24 | uint32(hb[11])<<16 | uint32(hb[12])<<8 | uint32(hb[13])
+	}
+	start := uint(xh.Size)
+	if start > uint(len(hb)) || uint(xh.Padding) > uint(len(hb))-start {
+		return nil, 0, errors.New(fmt.Sprintf("Invalid extended header (size %d, padding %d)", xsiz, xh.Padding))
+	}
+	if xh.HasCRC {
+		xh.CRCValid = crc32.ChecksumIEEE(hb[start:uint(len(hb))-uint(xh.Padding)]) == xh.CRC
+	}
+	return xh, start, nil
+}
+
+// Encode an ID3v2.3 extended header with the CRC of the frames, and the
+// size of the padding that follows them.
+func extHeader23(padding uint, crc uint32) []byte {
+	return []byte{
+		0, 0, 0, 10, // size, not including itself
+		byte(xflgCRC23 >> 8), byte(xflgCRC23 & 0xff),
+		byte(padding >> 24), byte(padding >> 16), byte(padding >> 8), byte(padding),
+		byte(crc >> 24), byte(crc >> 16), byte(crc >> 8), byte(crc),
+	}
+}
+
+// Encode an ID3v2.4 extended header with the CRC of the frames and the
+// padding.
+func extHeader24(crc uint32) []byte {
+	b := append(syncsafeBytes(12), 1, xflgCRC24, 5)
+	return append(b, byte(crc>>28), byte(crc>>21&0x7f), byte(crc>>14&0x7f), byte(crc>>7&0x7f), byte(crc&0x7f))
+}
+
+// Decode an ID3v2.4 extended header.
+//
+//	Extended header size   4 * %0xxxxxxx (including itself)
+//	Number of flag bytes   $01
+//	Extended Flags         $xx (%0bcd0000)
+//
+// Then, for each flag set, its data length and data:
+//
+//	b: tag is an update    $00
+//	c: CRC data present    $05, 5 * %0xxxxxxx (35-bit syncsafe CRC-32)
+//	d: tag restrictions    $01, %ppqrrstt
+//
+// The CRC covers everything that follows the extended header, padding
+// included.
+func parseExtHeader24(hb []byte) (*ExtendedHeader, uint, error) {
+	if len(hb) < 6 || !isSyncsafe(hb[:4]) {
+		return nil, 0, errors.New("Invalid extended header size")
+	}
+	xsiz := syncsafe(hb)
+	if xsiz < 6 || xsiz > uint(len(hb)) {
+		return nil, 0, errors.New(fmt.Sprintf("Invalid extended header size (%d)", xsiz))
+	}
+	if hb[4] != 1 {
+		return nil, 0, errors.New(fmt.Sprintf("Invalid count of extended flag bytes (%d)", hb[4]))
+	}
+	xflg := hb[5]
+	if xflg&^(xflgUpdate|xflgCRC24|xflgRestrictions) != 0 {
+		return nil, 0, errors.New(fmt.Sprintf("Invalid flags for extended tag (0x%x)", xflg))
+	}
+	xh := &ExtendedHeader{Size: uint32(xsiz), Update: xflg&xflgUpdate != 0}
+	d := hb[6:xsiz] // flags data
+	flag := func(f byte, l int) ([]byte, error) {
+		if xflg&f == 0 {
+			return nil, nil
+		}
+		if len(d) < 1 || int(d[0]) != l || len(d) < 1+l {
+			return nil, errors.New(fmt.Sprintf("Invalid data for extended flag 0x%x", f))
+		}
+		v := d[1 : 1+l]
+		d = d[1+l:]
+		return v, nil
+	}
+	if _, err := flag(xflgUpdate, 0); err != nil {
+		return nil, 0, err
+	}
+	crc, err := flag(xflgCRC24, 5)
+	if err != nil {
+		return nil, 0, err
+	}
+	if crc != nil {
+		if !isSyncsafe(crc) {
+			return nil, 0, errors.New("Invalid extended header CRC")
+		}
+		xh.HasCRC = true
+		xh.CRC = uint32(crc[0])<<28 | uint32(crc[1])<<21 | uint32(crc[2])<<14 | uint32(crc[3])<<7 | uint32(crc[4])
+		xh.CRCValid = crc32.ChecksumIEEE(hb[xsiz:]) == xh.CRC
+	}
+	r, err := flag(xflgRestrictions, 1)
+	if err != nil {
+		return nil, 0, err
+	}
+	if r != nil {
+		xh.Restrictions = parseRestrictions(r[0])
+	}
+	return xh, xsiz, nil
+}
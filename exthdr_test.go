@@ -0,0 +1,161 @@
+package id3v2
+
+import (
+	"bytes"
+	"fmt"
+	"hash/crc32"
+	"reflect"
+	"testing"
+)
+
+// Return an ID3v2 tag of a given version and header flags, made of an
+// extended header, a TIT2 frame and padding, followed by a footer if the
+// flags say so.
+func extTag(ver, flags byte, xh []byte, padding int) []byte {
+	body := append(append([]byte{}, xh...), "TIT2\x00\x00\x00\x06\x00\x00\x00Title"...)
+	body = append(body, make([]byte, padding)...)
+	b := append([]byte{'I', 'D', '3', ver, 0, flags}, syncsafeBytes(uint(len(body)))...)
+	b = append(b, body...)
+	if flags&0x10 != 0 {
+		b = append(b, '3', 'D', 'I')
+		b = append(b, b[3:10]...)
+	}
+	return b
+}
+
+func TestExtendedHeader(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		tag     []byte
+		want    ExtendedHeader
+		padding uint
+	}{
+		{"ID3v2.3", extTag(3, 0x40, []byte{0, 0, 0, 6, 0, 0, 0, 0, 0, 20}, 20), ExtendedHeader{Size: 10, Padding: 20}, 20},
+		{"ID3v2.4 update", extTag(4, 0x40, []byte{0, 0, 0, 7, 1, xflgUpdate, 0}, 20), ExtendedHeader{Size: 7, Update: true}, 20},
+		{"ID3v2.4 restrictions", extTag(4, 0x50, []byte{0, 0, 0, 8, 1, xflgRestrictions, 1, 0x6d}, 0),
+			ExtendedHeader{Size: 8, Restrictions: &TagRestrictions{TagSize: 1, TextEncoding: 1, TextSize: 1, ImageEncoding: 1, ImageSize: 1}}, 0},
+	} {
+		b := append(append([]byte{}, tc.tag...), mpegFrame...)
+		mi, err := ReadAllTags(bytes.NewReader(b), int64(len(b)))
+		if err != nil {
+			t.Fatalf("%s: %s", tc.name, err)
+		}
+		if pt := mi.AllTags["TIT2"]; pt == nil || pt.Value != "Title" {
+			t.Errorf("%s: TIT2 %+v", tc.name, pt)
+		}
+		tl := mi.Layouts[0]
+		if xh := tl.ExtendedHeader; xh == nil || !reflect.DeepEqual(*xh, tc.want) || mi.ExtendedHeader != xh {
+			t.Errorf("%s: extended header %+v, want %+v", tc.name, xh, tc.want)
+		}
+		if tl.Used != uint(tc.want.Size)+16 || tl.Padding != tc.padding || tl.End() != int64(len(tc.tag)) || mi.AudioStart != tl.End() {
+			t.Errorf("%s: used %d, padding %d, end %d, audio at %d", tc.name, tl.Used, tl.Padding, tl.End(), mi.AudioStart)
+		}
+		if tl.HasFooter() != (tc.tag[5]&0x10 != 0) {
+			t.Errorf("%s: footer %v", tc.name, tl.HasFooter())
+		}
+	}
+	r := &TagRestrictions{TagSize: 1, TextEncoding: 1, TextSize: 1, ImageEncoding: 1, ImageSize: 1}
+	if s := r.String(); s != "64 frames, 131072 bytes, ISO-8859-1 or UTF-8 text, 1024 chars per text, PNG or JPEG images, 256x256 max images" {
+		t.Errorf("restrictions %q", s)
+	}
+}
+
+func TestExtendedHeaderInvalid(t *testing.T) {
+	footer := extTag(4, 0x10, nil, 0)
+	footer[len(footer)-1] ^= 1 // the footer no longer matches the header
+	for name, tag := range map[string][]byte{
+		"ID3v2.3 size":      extTag(3, 0x40, []byte{0xff, 0xff, 0xff, 0xfc, 0, 0, 0, 0, 0, 0}, 20),
+		"ID3v2.3 size 8":    extTag(3, 0x40, []byte{0, 0, 0, 8, 0, 0, 0, 0, 0, 0, 0, 0}, 20),
+		"ID3v2.3 CRC size":  extTag(3, 0x40, []byte{0, 0, 0, 6, 0x80, 0, 0, 0, 0, 0}, 20),
+		"ID3v2.3 padding":   extTag(3, 0x40, []byte{0, 0, 0, 6, 0, 0, 0, 0, 0x10, 0}, 20),
+		"ID3v2.4 size":      extTag(4, 0x40, []byte{0, 0, 0x7f, 0x7f, 1, 0}, 20),
+		"ID3v2.4 flag data": extTag(4, 0x40, []byte{0, 0, 0, 7, 1, xflgUpdate, 1}, 20),
+		"ID3v2.4 footer":    footer,
+	} {
+		b := append(append([]byte{}, tag...), mpegFrame...)
+		if _, err := ReadAllTags(bytes.NewReader(b), int64(len(b))); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+		mi, err := ReadAllTagsWith(bytes.NewReader(b), int64(len(b)), &Options{Lenient: true})
+		if err != nil {
+			t.Errorf("%s: %s in lenient mode", name, err)
+		} else if pt := mi.AllTags["TIT2"]; pt == nil || pt.Value != "Title" || len(mi.Warnings) == 0 {
+			t.Errorf("%s: TIT2 %+v, warnings %q", name, pt, mi.Warnings)
+		}
+	}
+}
+
+func TestCRC(t *testing.T) {
+	for _, tc := range []struct {
+		ver    byte
+		unsync bool
+	}{
+		{3, false}, {3, true}, {4, false}, {4, true},
+	} {
+		name := fmt.Sprintf("ID3v2.%d, unsync %v", tc.ver, tc.unsync)
+		tag := encodedTag(t, tc.ver, tc.unsync, true)
+		read := func(b []byte) *MP3Info {
+			b = append(append([]byte{}, b...), mpegFrame...)
+			mi, err := ReadAllTags(bytes.NewReader(b), int64(len(b)))
+			if err != nil {
+				t.Fatalf("%s: %s", name, err)
+			}
+			return mi
+		}
+
+		// The CRC of the tag written is valid, and kept when the tag is
+		// written again.
+		mi := read(tag)
+		if checked, valid := mi.Layouts[0].CRCValid(); !checked || !valid || mi.BadCRC || len(mi.Warnings) != 0 {
+			t.Errorf("%s: CRC checked %v, valid %v, warnings %q", name, checked, valid, mi.Warnings)
+		}
+		if xh := mi.Layouts[0].ExtendedHeader; !tc.unsync {
+			start, end := 10+int(xh.Size), len(tag)
+			if tc.ver < 4 {
+				end -= int(xh.Padding)
+			}
+			if crc := crc32.ChecksumIEEE(tag[start:end]); xh.CRC != crc {
+				t.Errorf("%s: CRC 0x%08x, want 0x%08x", name, xh.CRC, crc)
+			}
+		}
+		nt := mi.Tag()
+		if !nt.CRC {
+			t.Errorf("%s: CRC dropped", name)
+		}
+		b, err := nt.Encode()
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if checked, valid := read(b).Layouts[0].CRCValid(); !checked || !valid {
+			t.Errorf("%s: CRC checked %v, valid %v once written again", name, checked, valid)
+		}
+
+		// A change of the frames is detected, but the frames are read.
+		b = bytes.Replace(tag, []byte("Title"), []byte("Tible"), 1)
+		mi = read(b)
+		if checked, valid := mi.Layouts[0].CRCValid(); !checked || valid || !mi.BadCRC || len(mi.Warnings) != 1 {
+			t.Errorf("%s: CRC checked %v, valid %v, warnings %q", name, checked, valid, mi.Warnings)
+		}
+		if pt := mi.AllTags["TIT2"]; pt == nil || pt.Value != "Tible" {
+			t.Errorf("%s: TIT2 %+v", name, pt)
+		}
+
+		// The ID3v2.4 CRC covers the padding, the ID3v2.3 one does not.
+		b = append([]byte{}, tag...)
+		b[len(b)-1] = 0x01
+		mi = read(b)
+		if _, valid := mi.Layouts[0].CRCValid(); valid != (tc.ver < 4) {
+			t.Errorf("%s: CRC valid %v with altered padding", name, valid)
+		}
+	}
+
+	// Without CRC.
+	b := append(encodedTag(t, 4, false, false), mpegFrame...)
+	mi, err := ReadAllTags(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checked, _ := mi.Layouts[0].CRCValid(); checked || mi.BadCRC || mi.Tag().CRC {
+		t.Error("CRC checked")
+	}
+}
@@ -0,0 +1,396 @@
+package id3v2
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	id3v1Size   = 128 // size of an ID3v1 tag
+	id3v1ExSize = 227 // size of an enhanced "TAG+" block
+)
+
+// ID3v1 holds the content of an ID3v1 or ID3v1.1 tag, found in the last 128
+// bytes of a file, and of the optional enhanced "TAG+" block that precedes
+// it. Title, Artist and Album include the extra characters of the TAG+
+// block, if any.
+type ID3v1 struct {
+	Title   string
+	Artist  string
+	Album   string
+	Year    string
+	Comment string
+	Track   byte // track number (ID3v1.1), or 0
+	Genre   byte // genre index (see GenreName), 255 if none
+
+	Enhanced  bool   // a TAG+ block is present
+	Speed     byte   // TAG+ speed (0: unset, 1: slow, 2: medium, 3: fast, 4: hardcore)
+	FreeGenre string // TAG+ free-text genre
+	StartTime string // TAG+ start of the music, as "mmm:ss"
+	EndTime   string // TAG+ end of the music, as "mmm:ss"
+
+	raw [][]byte // non-ASCII strings, for the charset detection
+}
+
+// Size returns the size of the tag in the file, TAG+ block included.
+func (v1 *ID3v1) Size() int64 {
+	if v1.Enhanced {
+		return id3v1Size + id3v1ExSize
+	}
+	return id3v1Size
+}
+
+// GenreName returns the name of the genre of the tag: the free-text genre of
+// the TAG+ block if any, the name of the genre index otherwise.
+func (v1 *ID3v1) GenreName() string {
+	if v1.FreeGenre != "" {
+		return v1.FreeGenre
+	}
+	if int(v1.Genre) < len(v1Genres) {
+		return v1Genres[v1.Genre]
+	}
+	return ""
+}
+
+// ID3v1 genres, Winamp extensions included.
+var v1Genres = []string{
+	"Blues", "Classic Rock", "Country", "Dance", "Disco", "Funk", "Grunge",
+	"Hip-Hop", "Jazz", "Metal", "New Age", "Oldies", "Other", "Pop", "R&B",
+	"Rap", "Reggae", "Rock", "Techno", "Industrial", "Alternative", "Ska",
+	"Death Metal", "Pranks", "Soundtrack", "Euro-Techno", "Ambient",
+	"Trip-Hop", "Vocal", "Jazz+Funk", "Fusion", "Trance", "Classical",
+	"Instrumental", "Acid", "House", "Game", "Sound Clip", "Gospel", "Noise",
+	"Alternative Rock", "Bass", "Soul", "Punk", "Space", "Meditative",
+	"Instrumental Pop", "Instrumental Rock", "Ethnic", "Gothic", "Darkwave",
+	"Techno-Industrial", "Electronic", "Pop-Folk", "Eurodance", "Dream",
+	"Southern Rock", "Comedy", "Cult", "Gangsta", "Top 40", "Christian Rap",
+	"Pop/Funk", "Jungle", "Native US", "Cabaret", "New Wave", "Psychedelic",
+	"Rave", "Showtunes", "Trailer", "Lo-Fi", "Tribal", "Acid Punk",
+	"Acid Jazz", "Polka", "Retro", "Musical", "Rock & Roll", "Hard Rock",
+	"Folk", "Folk-Rock", "National Folk", "Swing", "Fast Fusion", "Bebop",
+	"Latin", "Revival", "Celtic", "Bluegrass", "Avantgarde", "Gothic Rock",
+	"Progressive Rock", "Psychedelic Rock", "Symphonic Rock", "Slow Rock",
+	"Big Band", "Chorus", "Easy Listening", "Acoustic", "Humour", "Speech",
+	"Chanson", "Opera", "Chamber Music", "Sonata", "Symphony", "Booty Bass",
+	"Primus", "Porn Groove", "Satire", "Slow Jam", "Club", "Tango", "Samba",
+	"Folklore", "Ballad", "Power Ballad", "Rhythmic Soul", "Freestyle", "Duet",
+	"Punk Rock", "Drum Solo", "A capella", "Euro-House", "Dance Hall", "Goa",
+	"Drum & Bass", "Club-House", "Hardcore Techno", "Terror", "Indie",
+	"BritPop", "Negerpunk", "Polsk Punk", "Beat", "Christian Gangsta Rap",
+	"Heavy Metal", "Black Metal", "Crossover", "Contemporary Christian",
+	"Christian Rock", "Merengue", "Salsa", "Thrash Metal", "Anime", "Jpop",
+	"Synthpop", "Abstract", "Art Rock", "Baroque", "Bhangra", "Big Beat",
+	"Breakbeat", "Chillout", "Downtempo", "Dub", "EBM", "Eclectic", "Electro",
+	"Electroclash", "Emo", "Experimental", "Garage", "Global", "IDM",
+	"Illbient", "Industro-Goth", "Jam Band", "Krautrock", "Leftfield",
+	"Lounge", "Math Rock", "New Romantic", "Nu-Breakz", "Post-Punk",
+	"Post-Rock", "Psytrance", "Shoegaze", "Space Rock", "Trop Rock",
+	"World Music", "Neoclassical", "Audiobook", "Audio Theatre",
+	"Neue Deutsche Welle", "Podcast", "Indie Rock", "G-Funk", "Dubstep",
+	"Garage Rock", "Psybient",
+}
+
+// Return a fixed-size ID3v1 field as a string, without its trailing nulls
+// and spaces, decoded with a given charset (nil for ISO-8859-1).
+func (v1 *ID3v1) v1String(b []byte, cs encoding.Encoding) string {
+	if i := bytes.IndexByte(b, 0x00); i != -1 {
+		b = b[:i]
+	}
+	b = bytes.TrimRight(b, " ")
+	if hasHighBytes(b) {
+		v1.raw = append(v1.raw, b)
+	}
+	return decodeCharset(b, cs)
+}
+
+// Read the ID3v1 tag at the end of a file of a given size, if any. The
+// strings are decoded with a given charset (nil for ISO-8859-1).
+func readID3v1(sf io.ReaderAt, size int64, cs encoding.Encoding) (*ID3v1, error) {
+	if size < id3v1Size {
+		return nil, nil
+	}
+	b := make([]byte, id3v1ExSize+id3v1Size)
+	if size < int64(len(b)) {
+		b = b[id3v1ExSize:] // no room for a TAG+ block
+	}
+	_, err := sf.ReadAt(b, size-int64(len(b)))
+	if err != nil {
+		return nil, err
+	}
+	t := b[len(b)-id3v1Size:]
+	if string(t[:3]) != "TAG" {
+		return nil, nil
+	}
+
+	// TAG             3 bytes
+	// Title          30 bytes
+	// Artist         30 bytes
+	// Album          30 bytes
+	// Year            4 bytes
+	// Comment        30 bytes (28 bytes, $00 and track number in ID3v1.1)
+	// Genre           1 byte
+	v1 := &ID3v1{Genre: t[127]}
+	v1.Title = v1.v1String(t[3:33], cs)
+	v1.Artist = v1.v1String(t[33:63], cs)
+	v1.Album = v1.v1String(t[63:93], cs)
+	v1.Year = v1.v1String(t[93:97], cs)
+	if t[125] == 0x00 && t[126] != 0x00 { // ID3v1.1
+		v1.Comment = v1.v1String(t[97:125], cs)
+		v1.Track = t[126]
+	} else {
+		v1.Comment = v1.v1String(t[97:127], cs)
+	}
+	if Verbose >= 2 {
+		fmt.Printf("ID3v1 tag at 0x%x\n", size-id3v1Size)
+	}
+
+	// TAG+            4 bytes
+	// Title          60 bytes (characters following those of the ID3v1 title)
+	// Artist         60 bytes
+	// Album          60 bytes
+	// Speed           1 byte
+	// Genre          30 bytes
+	// Start time      6 bytes (mmm:ss)
+	// End time        6 bytes (mmm:ss)
+	if len(b) == id3v1ExSize+id3v1Size && string(b[:4]) == "TAG+" {
+		x := b[:id3v1ExSize]
+		v1.Enhanced = true
+		v1.Title += v1.v1String(x[4:64], cs)
+		v1.Artist += v1.v1String(x[64:124], cs)
+		v1.Album += v1.v1String(x[124:184], cs)
+		v1.Speed = x[184]
+		v1.FreeGenre = v1.v1String(x[185:215], cs)
+		v1.StartTime = v1.v1String(x[215:221], cs)
+		v1.EndTime = v1.v1String(x[221:227], cs)
+		if Verbose >= 2 {
+			fmt.Printf("Enhanced TAG+ block at 0x%x\n", size-id3v1Size-id3v1ExSize)
+		}
+	}
+	return v1, nil
+}
+
+// Add the fields of the ID3v1 tag to the processed tags. All the fields are
+// added to mi.Frames, but mi.AllTags only receives the ones that have no
+// ID3v2 value.
+func (mi *MP3Info) mergeID3v1() {
+	v1 := mi.ID3v1
+	if v1 == nil {
+		return
+	}
+	add := func(id, name, val string) {
+		if val == "" {
+			return
+		}
+		pt := &ProcessedTag{Name: name, Value: val, ID: id, Origin: OriginID3v1}
+		if Verbose >= 1 {
+			fmt.Printf("%s (ID3v1): %s\n", name, val)
+		}
+		mi.Frames = append(mi.Frames, pt)
+		if tagVal(&mi.AllTags, id) == "" {
+			mi.AllTags[id] = pt
+		}
+	}
+	add("TIT2", "Title", v1.Title)
+	add("TPE1", "Artist(s)", v1.Artist)
+	add("TALB", "Album", v1.Album)
+	add("TYER", "Year", v1.Year)
+	add("COMM", "Comment", v1.Comment)
+	if v1.Track != 0 {
+		add("TRCK", "Track", strconv.Itoa(int(v1.Track)))
+	}
+	add("TCON", "Content type", v1.GenreName())
+}
+
+// ID3v1 returns an ID3v1.1 tag derived from the frames of an ID3v2 tag:
+// TIT2, TPE1 (or TPE2), TALB, TYER (or the year of TDRC), the COMM frame
+// without description (or the first one), TRCK and TCON.
+func (t *Tag) ID3v1() *ID3v1 {
+	v1 := &ID3v1{Genre: 255}
+	v1.Title = t.textValue("TIT2")
+	if v1.Artist = t.textValue("TPE1"); v1.Artist == "" {
+		v1.Artist = t.textValue("TPE2")
+	}
+	v1.Album = t.textValue("TALB")
+	if v1.Year = t.textValue("TYER"); v1.Year == "" {
+		v1.Year = t.textValue("TDRC")
+	}
+	if len(v1.Year) > 4 {
+		v1.Year = v1.Year[:4]
+	}
+	for _, pt := range t.Frames {
+		if pt.ID != "COMM" || pt.Encrypted() {
+			continue
+		}
+		desc, text, ok := parseCOMM(pt.Payload)
+		if ok && (v1.Comment == "" || desc == "") {
+			v1.Comment = text
+			if desc == "" {
+				break
+			}
+		}
+	}
+	if n, err := parseLeadingInt(t.textValue("TRCK")); err == nil && n > 0 && n < 256 {
+		v1.Track = byte(n)
+	}
+	v1.Genre = genreIndex(t.textValue("TCON"))
+	return v1
+}
+
+// Return the value of the first text frame of a tag with a given ID, or ""
+// if there is none.
+func (t *Tag) textValue(id string) string {
+	for _, pt := range t.Frames {
+		if pt.ID == id && isTextFrame(pt) {
+			return strings.Join(textValues(pt), "/")
+		}
+	}
+	return ""
+}
+
+// Return the description and the text of a COMM frame payload.
+func parseCOMM(pl []byte) (desc, text string, ok bool) {
+	// Text encoding          $xx
+	// Language               $xx xx xx
+	// Short content descrip. <text string according to encoding> $00 (00)
+	// The actual text        <full text string according to encoding>
+	if len(pl) < 4 {
+		return "", "", false
+	}
+	et := pl[0]
+	db, tb, ok := splitString(et, pl[4:])
+	if !ok {
+		return "", "", false
+	}
+	tb, _, _ = splitString(et, tb)
+	desc, _ = decodeText(et, db)
+	text, _ = decodeText(et, tb)
+	return desc, text, true
+}
+
+// Return the ID3v1 genre index of a TCON value: "(17)", "(17)Rock", "17"
+// or "Rock". This returns 255 if the genre has no index.
+func genreIndex(s string) byte {
+	if i := strings.IndexByte(s, '/'); i != -1 {
+		s = s[:i] // first value
+	}
+	if strings.HasPrefix(s, "(") {
+		if i := strings.IndexByte(s, ')'); i != -1 {
+			s = s[1:i]
+		}
+	}
+	if n, err := strconv.Atoi(s); err == nil {
+		if n >= 0 && n < len(v1Genres) {
+			return byte(n)
+		}
+		return 255
+	}
+	for i, g := range v1Genres {
+		if strings.EqualFold(g, s) {
+			return byte(i)
+		}
+	}
+	return 255
+}
+
+// Replacements of characters that are not in ISO-8859-1, and have no
+// decomposition into an ISO-8859-1 character and accents.
+var latin1Replacements = map[rune]string{
+	'Đ': "D", 'đ': "d", 'Ħ': "H", 'ħ': "h", 'ı': "i", 'Ł': "L", 'ł': "l",
+	'Œ': "OE", 'œ': "oe", 'Ŧ': "T", 'ŧ': "t", 'ƒ': "f",
+	'‘': "'", '’': "'", '‚': ",", '“': "\"", '”': "\"", '„': "\"",
+	'–': "-", '—': "-", '…': "...", '€': "EUR", '™': "TM",
+}
+
+// Transliterate a string to ISO-8859-1, and truncate it to n bytes. Accented
+// characters lose their accents if needed, a few other characters are
+// replaced by their nearest equivalent, and the rest by '?'.
+func latin1String(s string, n int) []byte {
+	b := make([]byte, 0, n)
+	for _, c := range norm.NFC.String(s) {
+		var r string
+		switch {
+		case c <= 0xff:
+			r = string(rune(c))
+		case latin1Replacements[c] != "":
+			r = latin1Replacements[c]
+		default:
+			r = "?"
+			for _, d := range norm.NFD.String(string(c)) { // drop the accents
+				if d <= 0xff {
+					r = string(d)
+				} else if !unicode.Is(unicode.Mn, d) {
+					r = "?"
+					break
+				}
+			}
+		}
+		for _, d := range r {
+			if len(b) == n {
+				return bytes.TrimRight(b, " ")
+			}
+			b = append(b, byte(d))
+		}
+	}
+	return bytes.TrimRight(b, " ")
+}
+
+// Encode returns the 128-byte ID3v1 tag, ID3v1.1 if it has a track number.
+// The strings are transliterated to ISO-8859-1 and truncated to the size of
+// their fields. The TAG+ fields are not written.
+func (v1 *ID3v1) Encode() []byte {
+	b := make([]byte, id3v1Size)
+	copy(b, "TAG")
+	copy(b[3:33], latin1String(v1.Title, 30))
+	copy(b[33:63], latin1String(v1.Artist, 30))
+	copy(b[63:93], latin1String(v1.Album, 30))
+	copy(b[93:97], latin1String(v1.Year, 4))
+	if v1.Track != 0 {
+		copy(b[97:125], latin1String(v1.Comment, 28))
+		b[126] = v1.Track
+	} else {
+		copy(b[97:127], latin1String(v1.Comment, 30))
+	}
+	b[127] = v1.Genre
+	return b
+}
+
+// SaveID3v1 writes an ID3v1 tag at the end of a file, in place of its
+// current ID3v1 tag (and TAG+ block), if any. The modification time of the
+// file is preserved.
+func SaveID3v1(path string, v1 *ID3v1) error {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	old, err := readID3v1(f, fi.Size(), nil)
+	if err != nil {
+		return err
+	}
+	pos := fi.Size()
+	if old != nil {
+		pos -= old.Size()
+	}
+	if _, err = f.WriteAt(v1.Encode(), pos); err != nil {
+		return err
+	}
+	if err = f.Truncate(pos + id3v1Size); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Chtimes(path, fi.ModTime(), fi.ModTime())
+}
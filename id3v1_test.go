@@ -0,0 +1,186 @@
+package id3v2
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// Return a fixed-size field.
+func field(s string, n int) []byte {
+	b := make([]byte, n)
+	copy(b, s)
+	return b
+}
+
+// Return an ID3v1 tag, ID3v1.1 if track is not 0.
+func v1Tag(title, artist, album, year, comment string, track, genre byte) []byte {
+	b := []byte("TAG")
+	b = append(b, field(title, 30)...)
+	b = append(b, field(artist, 30)...)
+	b = append(b, field(album, 30)...)
+	b = append(b, field(year, 4)...)
+	if track != 0 {
+		b = append(b, field(comment, 28)...)
+		b = append(b, 0, track)
+	} else {
+		b = append(b, field(comment, 30)...)
+	}
+	return append(b, genre)
+}
+
+// Return an enhanced TAG+ block.
+func v1ExTag(title, artist, album string, speed byte, genre, start, end string) []byte {
+	b := []byte("TAG+")
+	b = append(b, field(title, 60)...)
+	b = append(b, field(artist, 60)...)
+	b = append(b, field(album, 60)...)
+	b = append(b, speed)
+	b = append(b, field(genre, 30)...)
+	b = append(b, field(start, 6)...)
+	return append(b, field(end, 6)...)
+}
+
+func TestReadID3v1(t *testing.T) {
+	audio := append(append([]byte{}, mpegFrame...), make([]byte, 400)...)
+	long := "A title longer than 30 charact"
+	for _, tc := range []struct {
+		name    string
+		trailer []byte
+		want    ID3v1
+	}{
+		{"ID3v1", v1Tag("Title", "Artist", "Album", "1999", "A 30-character comment, really", 0, 17),
+			ID3v1{Title: "Title", Artist: "Artist", Album: "Album", Year: "1999", Comment: "A 30-character comment, really", Genre: 17}},
+		{"ID3v1.1", v1Tag("T\xedtle  ", "Artist", "", "2001", "Comment", 7, 255),
+			ID3v1{Title: "Títle", Artist: "Artist", Year: "2001", Comment: "Comment", Track: 7, Genre: 255}},
+		{"TAG+", append(v1ExTag("ers, even", "", "", 3, "Chiptune", "000:10", "003:20"), v1Tag(long, "Artist", "Album", "2002", "", 1, 12)...),
+			ID3v1{Title: long + "ers, even", Artist: "Artist", Album: "Album", Year: "2002", Track: 1, Genre: 12,
+				Enhanced: true, Speed: 3, FreeGenre: "Chiptune", StartTime: "000:10", EndTime: "003:20"}},
+	} {
+		b := append(append([]byte{}, audio...), tc.trailer...)
+		mi, err := ReadAllTags(bytes.NewReader(b), int64(len(b)))
+		if err != nil {
+			t.Fatalf("%s: %s", tc.name, err)
+		}
+		v1 := mi.ID3v1
+		if v1 == nil {
+			t.Fatalf("%s: no ID3v1 tag", tc.name)
+		}
+		v1.raw = nil
+		if !reflect.DeepEqual(*v1, tc.want) {
+			t.Errorf("%s: %+v, want %+v", tc.name, *v1, tc.want)
+		}
+		if v1.Size() != int64(len(tc.trailer)) {
+			t.Errorf("%s: size %d", tc.name, v1.Size())
+		}
+		if pt := mi.AllTags["TIT2"]; pt == nil || pt.Value != tc.want.Title || pt.Origin != OriginID3v1 {
+			t.Errorf("%s: TIT2 %+v", tc.name, pt)
+		}
+		if pt := mi.AllTags["TCON"]; tc.want.Genre != 255 && (pt == nil || pt.Value != v1.GenreName()) {
+			t.Errorf("%s: TCON %+v", tc.name, pt)
+		}
+	}
+	if g := (&ID3v1{Genre: 17}).GenreName(); g != "Rock" {
+		t.Errorf("genre 17: %q", g)
+	}
+}
+
+func TestID3v1AfterID3v2(t *testing.T) {
+	// The ID3v2 frames take precedence, but all the ID3v1 fields are kept
+	// in Frames.
+	b, err := (&Tag{Version: 4, Frames: []*ProcessedTag{newTextFrame("TIT2", "Title", "v2 title")}}).Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b = append(b, mpegFrame...)
+	b = append(b, v1Tag("v1 title", "v1 artist", "", "", "", 0, 255)...)
+	mi, err := ReadAllTags(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mi.AllTags["TIT2"].Value != "v2 title" || mi.AllTags["TPE1"].Value != "v1 artist" {
+		t.Errorf("TIT2 %+v, TPE1 %+v", mi.AllTags["TIT2"], mi.AllTags["TPE1"])
+	}
+	n := 0
+	for _, pt := range mi.Frames {
+		if pt.Origin == OriginID3v1 {
+			n++
+		}
+	}
+	if n != 2 {
+		t.Errorf("%d ID3v1 frames", n)
+	}
+}
+
+func TestTagID3v1(t *testing.T) {
+	tag := &Tag{Version: 4}
+	tag.SetTitle("Title")
+	tag.SetArtist("Artist 1", "Artist 2")
+	tag.SetAlbum("Album")
+	tag.SetTrack(7, 12)
+	tag.AddComment("eng", "desc", "Described comment")
+	tag.AddComment("eng", "", "Comment")
+	tag.Frames = append(tag.Frames, newTextFrame("TDRC", "Recording time", "2001-02-03"), newTextFrame("TCON", "Content type", "(17)Rock"))
+	want := ID3v1{Title: "Title", Artist: "Artist 1/Artist 2", Album: "Album", Year: "2001", Comment: "Comment", Track: 7, Genre: 17}
+	if v1 := reload(t, tag).ID3v1(); !reflect.DeepEqual(*v1, want) {
+		t.Errorf("%+v, want %+v", *v1, want)
+	}
+
+	// TPE2 stands for a missing TPE1, the first comment for a missing
+	// comment without description, and genres are found by name.
+	tag = &Tag{Version: 3, Frames: []*ProcessedTag{
+		newTextFrame("TPE2", "Band", "Band"),
+		newTextFrame("TCON", "Content type", "hip-hop"),
+		newTextFrame("TRCK", "Track", "300"),
+	}}
+	tag.AddComment("eng", "desc", "Described comment")
+	want = ID3v1{Artist: "Band", Comment: "Described comment", Genre: 7}
+	if v1 := reload(t, tag).ID3v1(); !reflect.DeepEqual(*v1, want) {
+		t.Errorf("%+v, want %+v", *v1, want)
+	}
+}
+
+func TestEncodeID3v1(t *testing.T) {
+	for _, tc := range []struct {
+		v1   ID3v1
+		want []byte
+	}{
+		{ID3v1{Title: "Title", Artist: "Artist", Album: "Album", Year: "1999", Comment: "Comment", Genre: 17},
+			v1Tag("Title", "Artist", "Album", "1999", "Comment", 0, 17)},
+		{ID3v1{Title: "Café “Łódź” – Œuvre…", Artist: "Ћирилица", Year: "2001", Comment: "A comment of more than 28 characters", Track: 3, Genre: 255},
+			v1Tag("Caf\xe9 \"L\xf3dz\" - OEuvre...", "????????", "", "2001", "A comment of more than 28 ch", 3, 255)},
+		{ID3v1{Title: "A title of 30 characters, then", Album: "Trailing spaces are dropped   x", Comment: "A 30-character comment, really", Genre: 255},
+			v1Tag("A title of 30 characters, then", "", "Trailing spaces are dropped", "", "A 30-character comment, really", 0, 255)},
+	} {
+		if b := tc.v1.Encode(); !bytes.Equal(b, tc.want) {
+			t.Errorf("%+v: %q, want %q", tc.v1, b, tc.want)
+		}
+	}
+}
+
+func TestSaveID3v1(t *testing.T) {
+	audio := append(append([]byte{}, mpegFrame...), make([]byte, 400)...)
+	v1 := &ID3v1{Title: "New title", Track: 1, Genre: 255}
+	for name, trailer := range map[string][]byte{
+		"no tag": nil,
+		"ID3v1":  v1Tag("Old title", "", "", "", "", 0, 255),
+		"TAG+":   append(v1ExTag("", "", "", 0, "", "", ""), v1Tag("Old title", "", "", "", "", 0, 255)...),
+	} {
+		path := filepath.Join(t.TempDir(), "test.mp3")
+		if err := os.WriteFile(path, append(append([]byte{}, audio...), trailer...), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := SaveID3v1(path, v1); err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(b, append(append([]byte{}, audio...), v1.Encode()...)) {
+			t.Errorf("%s: %d bytes", name, len(b))
+		}
+	}
+}
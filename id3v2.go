@@ -2,17 +2,20 @@ package id3v2
 
 import (
 	"bytes"
+	"compress/zlib"
+	"encoding/binary"
 	"errors"
     "fmt"
-    "log"
+	"io"
     "os"
     "path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
 
 	"golang.org/x/text/encoding"
-	"golang.org/x/text/encoding/unicode"
 )
 
 // The Verbose variable can be used to set the level of verbosity of the
@@ -24,25 +27,103 @@ var Verbose	uint
 type ProcessedTag struct {
 	Name string		// understandable name
 	Value string	// tag value as a string
+	Data interface{}	// decoded value for structured frames (eg, *MLLT, *Commercial), or nil
+	ID string		// 4-char tag (APE item key or Lyrics3 field ID for these origins)
+	Flags uint16	// tag flags, in ID3v2.3 layout (see mp3Tag)
+	EncMethod byte	// encryption method symbol if the tag is encrypted
+	GroupID byte	// group symbol if the tag belongs to a group
+	Encryption *EncryptionMethod	// ENCR registration of EncMethod, if any
+	Group *GroupRegistration		// GRID registration of GroupID, if any
+	Origin TagOrigin	// kind of tag the value comes from
+	Err error		// *FrameError if the frame is invalid (Name is then "Error"), or nil
+	Values []string	// values of a text frame (Value holds them separated by '/')
+	Payload []byte	// raw frame data, without unsynchronisation and extra bytes, decompressed unless encrypted
+	uncSize uint	// decompressed size of a compressed and encrypted frame, or data length indicator of an encrypted frame
+	edited bool		// the payload of the text frame is to be built from its values (new, edited or converted frame)
+	tag *TagLayout	// ID3v2 tag of the frame
+}
+
+// TagOrigin tells which kind of tag of a file a processed tag comes from.
+type TagOrigin int
+
+const (
+	OriginID3v2 TagOrigin = iota	// ID3v2 frame
+	OriginID3v1						// ID3v1 or ID3v1.1 field
+	OriginAPE						// APEv1 or APEv2 item
+	OriginLyrics3					// Lyrics3v2 field
+)
+
+// String returns the name of the kind of tag.
+func (o TagOrigin) String() string {
+	switch o {
+	case OriginID3v2:
+		return "ID3v2"
+	case OriginID3v1:
+		return "ID3v1"
+	case OriginAPE:
+		return "APE"
+	case OriginLyrics3:
+		return "Lyrics3"
+	default:
+		return fmt.Sprintf("TagOrigin(%d)", int(o))
+	}
+}
+
+// Encrypted tells if the tag is encrypted, in which case its value could not
+// be decoded.
+func (pt *ProcessedTag) Encrypted() bool {
+	return pt.Flags & flgEncrypted != 0
+}
+
+// Known tells if the tag is known to the package. The payload of unknown
+// tags (eg, vendor frames) is kept as is.
+func (pt *ProcessedTag) Known() bool {
+	_, ok := tagmap[pt.ID]
+	return ok
+}
+
+// Grouped tells if the tag belongs to a group of tags.
+func (pt *ProcessedTag) Grouped() bool {
+	return pt.Flags & flgGroupId != 0
 }
 
 // Information for a MP3 file
 type MP3Info struct {
 	AllTags map[string]*ProcessedTag	// the map of all processed tags
+	Frames []*ProcessedTag				// all the processed tags, in processing order
 	BitRate int							// bitrate (from the first sample)
+	ExtendedHeader *ExtendedHeader		// extended header of the first ID3v2 tag that has one, if any
+	ID3v1 *ID3v1						// ID3v1 tag at the end of the file, if any
+	APE *APETag							// APE tag before the ID3v1 tag, if any
+	Lyrics3 *Lyrics3					// Lyrics3v2 tag before the ID3v1 tag, if any
+	Warnings []error					// problems recovered from in lenient mode, invalid frames and CRCs
+	Layouts []*TagLayout				// layout of the ID3v2 tags processed, in processing order
+	AudioStart int64					// file offset of the first data frame
+	Charset encoding.Encoding			// charset the ISO-8859-1 strings were decoded with, nil for ISO-8859-1
+	BadCRC bool							// the CRC-32 of an ID3v2 tag does not match its data (see TagLayout.CRCValid)
+	latin1 [][]byte						// non-ASCII ISO-8859-1 strings, for the charset detection
+	lenient bool						// recover from the errors of broken tags (see Lenient)
+	tagPos []int64						// offsets of the ID3v2 tags processed
 }
 
 // ID3v2 tag
 type mp3Tag struct {
+	version byte	// major version of the ID3v2 tag (3 for ID3v2.3.0)
 	tag	string		// 4-char tag
 	size uint		// payload size
-	flags uint16	// abc00000ijk00000 (see http://id3.org/id3v2.3.0)
+	flags uint16	// abc00000ijk000np (see http://id3.org/id3v2.3.0, n and p from ID3v2.4)
+	rawFlags uint16	// flags as found in the tag (0abc0000 0h00kmnp in ID3v2.4)
 	extra struct {
 		uncSize	uint	// uncompressed payload size if flgCompressed
 		encType byte	// encryption type if flgEncrypted
 		groupID byte	// group ID if flgGroupId
 	}
 	payload []byte
+	data interface{}	// decoded value set by the tag processing function, if any
+	values []string		// values of a text frame
+	err error			// decoding error of a frame whose value could be decoded anyway
+	charset encoding.Encoding	// actual charset of the ISO-8859-1 strings, or nil
+	latin1 [][]byte		// non-ASCII ISO-8859-1 strings of the frame
 }
 
 // Function for the processing of a tag.
@@ -58,6 +139,12 @@ const (
 	flgCompressed = 0x0080	// i: Compression. A 4-byte uncompressed length follows the tag header.
 	flgEncrypted  = 0x0040	// j: Encrypted. A 1-byte encyption type follows the tag header.
 	flgGroupId    = 0x0020	// k: Grouping Identity. A 1-byte group ID follows the tag header.
+	flgUnsync     = 0x0002	// n: Unsynchronisation (ID3v2.4 only).
+	flgDataLength = 0x0001	// p: Data length indicator (ID3v2.4 only). A 4-byte syncsafe length follows the tag header.
+
+	maxSeek = 16	// maximum count of SEEK frames followed
+	maxJunk = 0x10000	// maximum count of junk bytes before an ID3v2 tag
+	maxInflate = 1 << 24	// maximum size of a decompressed frame
 )
 
 var (
@@ -65,9 +152,11 @@ var (
 	tagmap = map[string]tagF{
 		"AENC":doAENC,	// Audio encryption
 		"APIC":doAPIC,	// Attached picture
+		"ASPI":doASPI,	// Audio seek point index (ID3v2.4)
 		"COMM":doCOMM,	// Comments
 		"COMR":doCOMR,	// Commercial frame
 		"ENCR":doENCR,	// Encryption method registration
+		"EQU2":doEQU2,	// Equalisation (2) (ID3v2.4)
 		"EQUA":doEQUA,	// Equalization
 		"ETCO":doETCO,	// Event timing codes
 		"GEOB":doGEOB,	// General encapsulated object
@@ -83,7 +172,10 @@ var (
 		"POSS":doPOSS,	// Position synchronisation frame
 		"RBUF":doRBUF,	// Recommended buffer size
 		"RVAD":doRVAD,	// Relative volume adjustment
+		"RVA2":doRVA2,	// Relative volume adjustment (2) (ID3v2.4)
 		"RVRB":doRVRB,	// Reverb
+		"SEEK":doSEEK,	// Seek frame (ID3v2.4)
+		"SIGN":doSIGN,	// Signature frame (ID3v2.4)
 		"SYLT":doSYLT,	// Synchronized lyric/text
 		"SYTC":doSYTC,	// Synchronized tempo codes
 		"TALB":doTALB,	// Album/Movie/Show title
@@ -92,18 +184,26 @@ var (
 		"TCON":doTCON,	// Content type
 		"TCOP":doTCOP,	// Copyright message
 		"TDAT":doTDAT,	// Date
+		"TDEN":doTDEN,	// Encoding time (ID3v2.4)
 		"TDLY":doTDLY,	// Playlist delay
+		"TDOR":doTDOR,	// Original release time (ID3v2.4)
+		"TDRC":doTDRC,	// Recording time (ID3v2.4)
+		"TDRL":doTDRL,	// Release time (ID3v2.4)
+		"TDTG":doTDTG,	// Tagging time (ID3v2.4)
 		"TENC":doTENC,	// Encoded by
 		"TEXT":doTEXT,	// Lyricist/Text writer
 		"TFLT":doTFLT,	// File type
 		"TIME":doTIME,	// Time
+		"TIPL":doTIPL,	// Involved people list (ID3v2.4)
 		"TIT1":doTIT1,	// Content group description
 		"TIT2":doTIT2,	// Title/songname/content description
 		"TIT3":doTIT3,	// Subtitle/Description refinement
 		"TKEY":doTKEY,	// Initial key
 		"TLAN":doTLAN,	// Language(s)
 		"TLEN":doTLEN,	// Length
+		"TMCL":doTMCL,	// Musician credits list (ID3v2.4)
 		"TMED":doTMED,	// Media type
+		"TMOO":doTMOO,	// Mood (ID3v2.4)
 		"TOAL":doTOAL,	// Original album/movie/show title
 		"TOFN":doTOFN,	// Original filename
 		"TOLY":doTOLY,	// Original lyricist(s)/text writer(s)
@@ -115,14 +215,19 @@ var (
 		"TPE3":doTPE3,	// Conductor/performer refinement
 		"TPE4":doTPE4,	// Interpreted, remixed, or otherwise modified by
 		"TPOS":doTPOS,	// Part of a set
+		"TPRO":doTPRO,	// Produced notice (ID3v2.4)
 		"TPUB":doTPUB,	// Publisher
 		"TRCK":doTRCK,	// Track number/Position in set
 		"TRDA":doTRDA,	// Recording dates
 		"TRSN":doTRSN,	// Internet radio station name
 		"TRSO":doTRSO,	// Internet radio station owner
 		"TSIZ":doTSIZ,	// Size
+		"TSOA":doTSOA,	// Album sort order (ID3v2.4)
+		"TSOP":doTSOP,	// Performer sort order (ID3v2.4)
+		"TSOT":doTSOT,	// Title sort order (ID3v2.4)
 		"TSRC":doTSRC,	// ISRC (international standard recording code)
 		"TSSE":doTSSE,	// Software/Hardware and settings used for encoding
+		"TSST":doTSST,	// Set subtitle (ID3v2.4)
 		"TYER":doTYER,	// Year
 		"TXXX":doTXXX,	// User defined text information frame
 		"UFID":doUFID,	// Unique file identifier
@@ -204,37 +309,175 @@ func decodeISO8859(src []byte) string {
 	return string(dst)
 }
 
-func decodeUtf16With(buf []byte, enc encoding.Encoding) string {
-	decoder := enc.NewDecoder()
-	dst := make([]byte, len(buf)*2)
-	nDst, _, err := decoder.Transform(dst, buf, true)
-	if err != nil {
-		log.Print(err)
+// Decode a UTF-16 string of a given byte order. Unpaired surrogates and a
+// trailing odd byte are reported as errors (and decoded as U+FFFD).
+func decodeUTF16(b []byte, order binary.ByteOrder) (string, error) {
+	var err error
+	if len(b) % 2 != 0 {
+		err = errors.New("Odd length of UTF-16 string")
+		b = b[:len(b) - 1]
+	}
+	u := make([]uint16, len(b) / 2)
+	for i := range u {
+		u[i] = order.Uint16(b[2 * i:])
+	}
+	for i := 0; i < len(u); i++ {
+		if !utf16.IsSurrogate(rune(u[i])) {
+			continue
+		}
+		if u[i] < 0xdc00 && i + 1 < len(u) && u[i + 1] >= 0xdc00 && u[i + 1] < 0xe000 {	// valid pair
+			i++
+			continue
+		}
+		if err == nil {
+			err = errors.New(fmt.Sprintf("Unpaired surrogate (0x%04x) in UTF-16 string", u[i]))
+		}
+	}
+	return string(utf16.Decode(u)), err
+}
+
+// Decode a UTF-16 string that starts with a BOM, or that has the given byte
+// order if it does not.
+func decodeUTF16BOM(b []byte, order binary.ByteOrder) (string, error) {
+	switch {
+	case len(b) >= 2 && b[0] == 0xff && b[1] == 0xfe:
+		return decodeUTF16(b[2:], binary.LittleEndian)
+	case len(b) >= 2 && b[0] == 0xfe && b[1] == 0xff:
+		return decodeUTF16(b[2:], binary.BigEndian)
+	default:
+		return decodeUTF16(b, order)
+	}
+}
+
+// Guess the byte order of a UTF-16 string without a BOM, from the position
+// of its null bytes (mostly Latin text is expected).
+func guessUTF16Order(b []byte) binary.ByteOrder {
+	var even, odd int
+	for i, c := range b {
+		if c != 0x00 {
+			continue
+		}
+		if i % 2 == 0 {
+			even++
+		} else {
+			odd++
+		}
+	}
+	if even > odd {
+		return binary.BigEndian
+	}
+	return binary.LittleEndian
+}
+
+// Return the text of a Txxx tag. The text may hold several values (ID3v2.4),
+// separated by null characters: they are set in frame.values, and returned
+// separated by '/'. Terminators are stripped, and the first decoding error,
+// if any, is set in frame.err.
+// ID3v2.2 and ID3v2.3 text frames hold a single string (two for TXXX, the
+// description and the value, and a list for IPLS): whatever follows its
+// terminator is ignored.
+func textFrame(frame *mp3Tag) string {
+	if len(frame.payload) == 0 {	// not even an encoding byte
 		return ""
 	}
-	return string(dst[:nDst])
+	et, pl := frame.payload[0], frame.payload[1:]
+	max := -1	// count of strings, -1 for no limit
+	if frame.version < 4 {
+		switch frame.tag {
+		case "TXXX":
+			max = 2
+		case "IPLS":	// people and their involvement, in pairs
+		default:
+			max = 1
+		}
+	}
+	var values []string
+	for len(pl) > 0 && len(values) != max {
+		var s []byte
+		s, pl, _ = splitString(et, pl)
+		v, err := frame.decodeText(et, s)
+		if err != nil && frame.err == nil {
+			frame.err = err
+		}
+		values = append(values, v)
+	}
+	for len(values) > 0 && values[len(values) - 1] == "" {	// terminators
+		values = values[:len(values) - 1]
+	}
+	frame.values = values
+	return strings.Join(values, "/")
+}
+
+// Decode a string of a frame, where ISO-8859-1 may actually be another
+// charset.
+func (frame *mp3Tag) decodeText(et byte, pl []byte) (string, error) {
+	if et != 0x00 {
+		return decodeText(et, pl)
+	}
+	if hasHighBytes(pl) {
+		frame.latin1 = append(frame.latin1, pl)
+	}
+	return decodeCharset(pl, frame.charset), nil
 }
 
-// Return the text of a Txxx tag.
-func textFrame(payload []byte) string {
-	et := payload[0]	// encoding byte
-	pl := payload[1:]	// actual payload
+// Decode a string, without terminator, according to a text encoding byte.
+// An invalid string is decoded as well as possible, and reported.
+func decodeText(et byte, pl []byte) (string, error) {
 	switch et {
 	case 0x00:	// ISO 8859-1
-		return decodeISO8859(pl)
+		return decodeISO8859(pl), nil
 	case 0x01:	// UTF-16, starting with a BOM
-		return decodeUtf16With(pl, unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM))
-	case 0x02:	// UTF-16BE string without BOM???
-		return decodeUtf16With(pl, unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM))
-	case 0x03:	// UTF-8 string
-		return string(pl)
+		if len(pl) >= 2 && (pl[0] == 0xff && pl[1] == 0xfe || pl[0] == 0xfe && pl[1] == 0xff) {
+			return decodeUTF16BOM(pl, binary.BigEndian)
+		}
+		if len(pl) == 0 {
+			return "", nil
+		}
+		s, err := decodeUTF16(pl, guessUTF16Order(pl))
+		if err == nil {
+			err = errors.New("Missing BOM in UTF-16 string")
+		}
+		return s, err
+	case 0x02:	// UTF-16BE, without BOM
+		return decodeUTF16(pl, binary.BigEndian)
+	case 0x03:	// UTF-8
+		if !utf8.Valid(pl) {
+			return strings.ToValidUTF8(string(pl), "\uFFFD"), errors.New("Invalid UTF-8 string")
+		}
+		return string(pl), nil
 	default:
-		return fmt.Sprintf("UNKNOWN ENCODING (0x%02x)", et)
+		return "", errors.New(fmt.Sprintf("Unknown text encoding (0x%02x)", et))
 	}
 }
 
+// Split a terminated string from the beginning of pl, according to the text
+// encoding et (the terminator is $00 00 for UTF-16 strings, $00 otherwise).
+// This returns the string bytes, without the terminator, and the bytes that
+// follow the terminator. If no terminator is found, the whole pl is returned
+// as the string and ok is false.
+func splitString(et byte, pl []byte) (str, rest []byte, ok bool) {
+	if et == 0x01 || et == 0x02 {
+		for i := 0; i + 1 < len(pl); i += 2 {
+			if pl[i] == 0x00 && pl[i + 1] == 0x00 {
+				return pl[:i], pl[i + 2:], true
+			}
+		}
+		return pl, nil, false
+	}
+	i := bytes.IndexByte(pl, 0x00)
+	if i == -1 {
+		return pl, nil, false
+	}
+	return pl[:i], pl[i + 1:], true
+}
+
 func doAENC(frame *mp3Tag) (string, string) {	// Audio encryption
-	return "", ""
+	a, err := parseAENC(frame.payload)
+	if err != nil {
+		return "Error", err.Error()
+	}
+	frame.data = a
+	return "Audio encryption", fmt.Sprintf("%q, preview %d+%d frames, %d bytes", a.Owner, a.PreviewStart, a.PreviewLength, len(a.Info))
 }
 func doAPIC(frame *mp3Tag) (string, string) {	// Attached picture
 // Text encoding   $xx
@@ -242,39 +485,66 @@ func doAPIC(frame *mp3Tag) (string, string) {	// Attached picture
 // Picture type    $xx
 // Description     <text string according to encoding> $00 (00)
 // Picture data    <binary data>
+	if len(frame.payload) < 1 {
+		return "Error", "Empty APIC frame"
+	}
 	et := frame.payload[0]		// encoding byte
-	i := bytes.IndexByte(frame.payload[1:], 0x00)
-//fmt.Println("i = ", i)
-	if i == -1 {
+	mt, pl, ok := splitString(0x00, frame.payload[1:])
+	if !ok || len(pl) < 1 {
 		return "Error", "Cannot find MIME type termination in APIC frame"
 	}
-	mimeType := textFrame(frame.payload[:1 + i])
-	pictureType := frame.payload[1 + i + 1]
+	mimeType := decodeISO8859(mt)
+	pictureType := pl[0]
 //fmt.Printf("mimeType = %s, pictureType = %d\n", mimeType, pictureType)
-	j := bytes.IndexByte(frame.payload[1 + i + 1 + 1:], 0x00)
-//fmt.Println("j = ", j)
-	if j == -1 {
+	db, pl, ok := splitString(et, pl[1:])
+	if !ok {
 		return "Error", "Cannot find Description termination in APIC frame"
 	}
-	var db = make([]byte, 1 + j)
-	db[0] = et
-	copy(db[1:], frame.payload[1 + i + 1 + 1:1 + i + 1 + 1 + j])
-	description := textFrame(db)
-	bytes := len(frame.payload) - (1 + i + 1 + 1 + j + 1)
-	return "Picture", fmt.Sprintf("%s, 0x%02x, \"%s\", %d (0x%x) bytes", mimeType, pictureType, description, bytes, bytes)
+	description, err := frame.decodeText(et, db)
+	if err != nil {
+		frame.err = err
+	}
+	p := &Picture{MIMEType:mimeType, Type:pictureType, Description:description, Data:pl}
+	frame.data = p
+	return "Picture", p.String()
+}
+func doASPI(frame *mp3Tag) (string, string) {	// Audio seek point index
+	a, err := parseASPI(frame.payload)
+	if err != nil {
+		return "Error", err.Error()
+	}
+	frame.data = a
+	return "Seek point index", fmt.Sprintf("%d points over 0x%x bytes from 0x%x", len(a.Points), a.Length, a.Start)
 }
 func doCOMM(frame *mp3Tag) (string, string) {	// Comments
-	return "", ""
+	_, text, ok := parseCOMM(frame.payload)
+	if !ok {
+		return "Error", "Truncated COMM frame"
+	}
+	return "Comment", text
 }
 func doCOMR(frame *mp3Tag) (string, string) {	// Commercial frame
-	return "", ""
+	c, err := parseCOMR(frame.payload)
+	if c == nil {
+		return "Error", err.Error()
+	}
+	frame.data, frame.err = c, err	// err: invalid string, decoded anyway
+	return "Commercial", fmt.Sprintf("%s, valid until %s, from %q (%s)", formatPrices(c.Prices), c.ValidUntil.Format("2006-01-02"), c.Seller, c.ContactURL)
 }
 func doENCR(frame *mp3Tag) (string, string) {	// Encryption method registration
-	return "", ""
+	e, err := parseENCR(frame.payload)
+	if err != nil {
+		return "Error", err.Error()
+	}
+	frame.data = e
+	return "Encryption method", fmt.Sprintf("0x%02x: %q, %d bytes", e.Method, e.Owner, len(e.Data))
 }
 func doEQUA(frame *mp3Tag) (string, string) {	// Equalization
 	return "", ""
 }
+func doEQU2(frame *mp3Tag) (string, string) {	// Equalisation (2)
+	return "", ""
+}
 func doETCO(frame *mp3Tag) (string, string) {	// Event timing codes
 	return "", ""
 }
@@ -282,48 +552,113 @@ func doGEOB(frame *mp3Tag) (string, string) {	// General encapsulated object
 	return "", ""
 }
 func doGRID(frame *mp3Tag) (string, string) {	// Group identification registration
-	return "", ""
+	g, err := parseGRID(frame.payload)
+	if err != nil {
+		return "Error", err.Error()
+	}
+	frame.data = g
+	return "Group", fmt.Sprintf("0x%02x: %q, %d bytes", g.Symbol, g.Owner, len(g.Data))
 }
 func doIPLS(frame *mp3Tag) (string, string) {	// Involved people list
-	return "", ""
+	return "Involved people", textFrame(frame)
 }
 func doLINK(frame *mp3Tag) (string, string) {	// Linked information
-	return "", ""
+	l, err := parseLINK(frame.payload, frame.version)
+	if err != nil {
+		return "Error", err.Error()
+	}
+	frame.data = l
+	return "Linked information", fmt.Sprintf("%s at %s %q", l.FrameID, l.URL, l.Data)
 }
 func doMCDI(frame *mp3Tag) (string, string) {	// Music CD identifier
-	return "", ""
+	toc, err := parseMCDI(frame.payload)
+	if err != nil {
+		return "Error", err.Error()
+	}
+	frame.data = toc
+	return "CD identifier", fmt.Sprintf("%d tracks, CDDB %s, MusicBrainz %s", len(toc.Tracks), toc.CDDBID(), toc.MusicBrainzID())
 }
 func doMLLT(frame *mp3Tag) (string, string) {	// MPEG location lookup table
-	return "", ""
+	m, err := parseMLLT(frame.payload)
+	if err != nil {
+		return "Error", err.Error()
+	}
+	frame.data = m
+	return "MPEG lookup table", fmt.Sprintf("%d references, every %d frames (%d bytes, %d ms)", m.Len(), m.FramesBetweenRef, m.BytesBetweenRef, m.MsBetweenRef)
 }
 func doOWNE(frame *mp3Tag) (string, string) {	// Ownership frame
-	return "", ""
+	o, err := parseOWNE(frame.payload)
+	if o == nil {
+		return "Error", err.Error()
+	}
+	frame.data, frame.err = o, err	// err: invalid string, decoded anyway
+	return "Ownership", fmt.Sprintf("%s on %s from %q", formatPrices(o.Prices), o.Purchased.Format("2006-01-02"), o.Seller)
 }
 func doPRIV(frame *mp3Tag) (string, string) {	// Private frame
 	i := bytes.IndexByte(frame.payload, 0x00)	// <text string> $00 <private data> expected
 	if i == -1 {
 		return "Error", "Missing 0x00 in PRIV frame"
 	}	
-	return string(frame.payload[0:i]), fmt.Sprintf("%d bytes", len(frame.payload) - i - 1)
+	p := &PrivateFrame{Owner:string(frame.payload[0:i]), Data:frame.payload[i + 1:]}
+	frame.data = p
+	dec, ok := privateDecoder(p.Owner)
+	if !ok {
+		return p.Owner, fmt.Sprintf("%d bytes", len(p.Data))
+	}
+	v, err := dec(p.Data)
+	if err != nil {
+		return p.Owner, fmt.Sprintf("%d bytes (%s)", len(p.Data), err.Error())
+	}
+	p.Value = v
+	return p.Owner, fmt.Sprint(v)
 }
 func doPCNT(frame *mp3Tag) (string, string) {	// Play counter
 	return "", ""
 }
 func doPOPM(frame *mp3Tag) (string, string) {	// Popularimeter
-	return "", ""
+	p, err := parsePOPM(frame.payload)
+	if err != nil {
+		return "Error", err.Error()
+	}
+	frame.data = p
+	return "Popularimeter", p.String()
 }
 func doPOSS(frame *mp3Tag) (string, string) {	// Position synchronisation frame
-	return "", ""
+	p, err := parsePOSS(frame.payload)
+	if err != nil {
+		return "Error", err.Error()
+	}
+	frame.data = p
+	return "Position", p.String()
 }
 func doRBUF(frame *mp3Tag) (string, string) {	// Recommended buffer size
-	return "", ""
+	r, err := parseRBUF(frame.payload)
+	if err != nil {
+		return "Error", err.Error()
+	}
+	frame.data = r
+	return "Buffer size", fmt.Sprintf("%d bytes, embedded info %t, next tag at +0x%x", r.Size, r.Embedded, r.NextTag)
 }
 func doRVAD(frame *mp3Tag) (string, string) {	// Relative volume adjustment
 	return "", ""
 }
+func doRVA2(frame *mp3Tag) (string, string) {	// Relative volume adjustment (2)
+	return "", ""
+}
 func doRVRB(frame *mp3Tag) (string, string) {	// Reverb
 	return "", ""
 }
+func doSEEK(frame *mp3Tag) (string, string) {	// Seek frame
+	t, err := parseSEEK(frame.payload)
+	if err != nil {
+		return "Error", err.Error()
+	}
+	frame.data = t
+	return "Next tag", fmt.Sprintf("+0x%x bytes", t.Offset)
+}
+func doSIGN(frame *mp3Tag) (string, string) {	// Signature frame
+	return "", ""
+}
 func doSYLT(frame *mp3Tag) (string, string) {	// Synchronized lyric/text
 	return "", ""
 }
@@ -331,130 +666,178 @@ func doSYTC(frame *mp3Tag) (string, string) {	// Synchronized tempo codes
 	return "", ""
 }
 func doTALB(frame *mp3Tag) (string, string) {	// Album/Movie/Show title
-	return "Album", textFrame(frame.payload)
+	return "Album", textFrame(frame)
 }
 func doTBPM(frame *mp3Tag) (string, string) {	// BPM (beats per minute)
-	return "BPM", textFrame(frame.payload)
+	return "BPM", textFrame(frame)
 }
 func doTCOM(frame *mp3Tag) (string, string) {	// Composer
-	return "Composer", textFrame(frame.payload)
+	return "Composer", textFrame(frame)
 }
 func doTCON(frame *mp3Tag) (string, string) {	// Content type
-	return "Content type", textFrame(frame.payload)
+	return "Content type", textFrame(frame)
 }
 func doTCOP(frame *mp3Tag) (string, string) {	// Copyright message
-	return "Copyright", textFrame(frame.payload)
+	return "Copyright", textFrame(frame)
 }
 func doTDAT(frame *mp3Tag) (string, string) {	// Date
-	return "Playlist delay", textFrame(frame.payload)
+	return "Playlist delay", textFrame(frame)
+}
+func doTDEN(frame *mp3Tag) (string, string) {	// Encoding time
+	return "Encoded on", textFrame(frame)
 }
 func doTDLY(frame *mp3Tag) (string, string) {	// Playlist delay
-	return "", textFrame(frame.payload)
+	return "", textFrame(frame)
+}
+func doTDOR(frame *mp3Tag) (string, string) {	// Original release time
+	return "Original release time", textFrame(frame)
+}
+func doTDRC(frame *mp3Tag) (string, string) {	// Recording time
+	return "Recorded on", textFrame(frame)
+}
+func doTDRL(frame *mp3Tag) (string, string) {	// Release time
+	return "Released on", textFrame(frame)
+}
+func doTDTG(frame *mp3Tag) (string, string) {	// Tagging time
+	return "Tagged on", textFrame(frame)
 }
 func doTENC(frame *mp3Tag) (string, string) {	// Encoded by
-	return "Encoded by", textFrame(frame.payload)
+	return "Encoded by", textFrame(frame)
 }
 func doTEXT(frame *mp3Tag) (string, string) {	// Lyricist/Text writer
-	return "Lyrics by", textFrame(frame.payload)
+	return "Lyrics by", textFrame(frame)
 }
 func doTFLT(frame *mp3Tag) (string, string) {	// File type
-	return "", textFrame(frame.payload)
+	return "", textFrame(frame)
 }
 func doTIME(frame *mp3Tag) (string, string) {	// Time
-	return "Time", textFrame(frame.payload)
+	return "Time", textFrame(frame)
+}
+func doTIPL(frame *mp3Tag) (string, string) {	// Involved people list
+	return "Involved people", textFrame(frame)
 }
 func doTIT1(frame *mp3Tag) (string, string) {	// Content group description
-	return "Content group", textFrame(frame.payload)
+	return "Content group", textFrame(frame)
 }
 func doTIT2(frame *mp3Tag) (string, string) {	// Title/songname/content description
-	return "Title", textFrame(frame.payload)
+	return "Title", textFrame(frame)
 }
 func doTIT3(frame *mp3Tag) (string, string) {	// Subtitle/Description refinement
-	return "Also", textFrame(frame.payload)
+	return "Also", textFrame(frame)
 }
 func doTKEY(frame *mp3Tag) (string, string) {	// Initial key
-	return "Initial key", textFrame(frame.payload)
+	return "Initial key", textFrame(frame)
 }
 func doTLAN(frame *mp3Tag) (string, string) {	// Language(s)
-	return "Language(s)", textFrame(frame.payload)
+	return "Language(s)", textFrame(frame)
 }
 func doTLEN(frame *mp3Tag) (string, string) {	// Length
-	return "Length", textFrame(frame.payload)
+	return "Length", textFrame(frame)
+}
+func doTMCL(frame *mp3Tag) (string, string) {	// Musician credits list
+	return "Musicians", textFrame(frame)
 }
 func doTMED(frame *mp3Tag) (string, string) {	// Media type
-	return "Media type", textFrame(frame.payload)
+	return "Media type", textFrame(frame)
+}
+func doTMOO(frame *mp3Tag) (string, string) {	// Mood
+	return "Mood", textFrame(frame)
 }
 func doTOAL(frame *mp3Tag) (string, string) {	// Original album/movie/show title
-	return "Original album", textFrame(frame.payload)
+	return "Original album", textFrame(frame)
 }
 func doTOFN(frame *mp3Tag) (string, string) {	// Original filename
-	return "Original filename", textFrame(frame.payload)
+	return "Original filename", textFrame(frame)
 }
 func doTOLY(frame *mp3Tag) (string, string) {	// Original lyricist(s)/text writer(s)
-	return "Original lyricist(s)", textFrame(frame.payload)
+	return "Original lyricist(s)", textFrame(frame)
 }
 func doTOPE(frame *mp3Tag) (string, string) {	// Original artist(s)/performer(s)
-	return "Original artist(s)", textFrame(frame.payload)
+	return "Original artist(s)", textFrame(frame)
 }
 func doTORY(frame *mp3Tag) (string, string) {	// Original release year
-	return "Original release year", textFrame(frame.payload)
+	return "Original release year", textFrame(frame)
 }
 func doTOWN(frame *mp3Tag) (string, string) {	// File owner/licensee
-	return "Owner", textFrame(frame.payload)
+	return "Owner", textFrame(frame)
 }
 func doTPE1(frame *mp3Tag) (string, string) {	// Lead performer(s)/Soloist(s)
-	return "Artist(s)", textFrame(frame.payload)
+	return "Artist(s)", textFrame(frame)
 }
 func doTPE2(frame *mp3Tag) (string, string) {	// Band/orchestra/accompaniment
-	return "Band", textFrame(frame.payload)
+	return "Band", textFrame(frame)
 }
 func doTPE3(frame *mp3Tag) (string, string) {	// Conductor/performer refinement
-	return "Also", textFrame(frame.payload)
+	return "Also", textFrame(frame)
 }
 func doTPE4(frame *mp3Tag) (string, string) {	// Interpreted, remixed, or otherwise modified by
-	return "Modified by", textFrame(frame.payload)
+	return "Modified by", textFrame(frame)
 }
 func doTPOS(frame *mp3Tag) (string, string) {	// Part of a set
-	return "Part", textFrame(frame.payload)
+	return "Part", textFrame(frame)
+}
+func doTPRO(frame *mp3Tag) (string, string) {	// Produced notice
+	return "Produced", textFrame(frame)
 }
 func doTPUB(frame *mp3Tag) (string, string) {	// Publisher
-	return "Publisher", textFrame(frame.payload)
+	return "Publisher", textFrame(frame)
 }
 func doTRCK(frame *mp3Tag) (string, string) {	// Track number/Position in set
-	return "Track", textFrame(frame.payload)
+	return "Track", textFrame(frame)
 }
 func doTRDA(frame *mp3Tag) (string, string) {	// Recording dates
-	return "Recorded on", textFrame(frame.payload)
+	return "Recorded on", textFrame(frame)
 }
 func doTRSN(frame *mp3Tag) (string, string) {	// Internet radio station name
-	return "Radio", textFrame(frame.payload)
+	return "Radio", textFrame(frame)
 }
 func doTRSO(frame *mp3Tag) (string, string) {	// Internet radio station owner
-	return "Radio owner", textFrame(frame.payload)
+	return "Radio owner", textFrame(frame)
 }
 func doTSIZ(frame *mp3Tag) (string, string) {	// Size
-	return "Size", textFrame(frame.payload)
+	return "Size", textFrame(frame)
+}
+func doTSOA(frame *mp3Tag) (string, string) {	// Album sort order
+	return "Album sort order", textFrame(frame)
+}
+func doTSOP(frame *mp3Tag) (string, string) {	// Performer sort order
+	return "Performer sort order", textFrame(frame)
+}
+func doTSOT(frame *mp3Tag) (string, string) {	// Title sort order
+	return "Title sort order", textFrame(frame)
 }
 func doTSRC(frame *mp3Tag) (string, string) {	// ISRC (international standard recording code)
-	return "ISRC", textFrame(frame.payload)
+	return "ISRC", textFrame(frame)
 }
 func doTSSE(frame *mp3Tag) (string, string) {	// Software/Hardware and settings used for encoding
-	return "Encoding settings", textFrame(frame.payload)
+	return "Encoding settings", textFrame(frame)
+}
+func doTSST(frame *mp3Tag) (string, string) {	// Set subtitle
+	return "Set subtitle", textFrame(frame)
 }
 func doTYER(frame *mp3Tag) (string, string) {	// Year
-	return "Year", textFrame(frame.payload)
+	return "Year", textFrame(frame)
 }
 func doTXXX(frame *mp3Tag) (string, string) {	// User defined text information frame
-	return "User defined", textFrame(frame.payload)
+	return "User defined", textFrame(frame)
 }
 func doUFID(frame *mp3Tag) (string, string) {	// Unique file identifier
 	return "", ""
 }
 func doUSER(frame *mp3Tag) (string, string) {	// Terms of use
-	return "", ""
+	u, err := parseUSER(frame.payload)
+	if u == nil {
+		return "Error", err.Error()
+	}
+	frame.data, frame.err = u, err	// err: invalid string, decoded anyway
+	return "Terms of use", fmt.Sprintf("[%s] %s", u.Language, u.Text)
 }
 func doUSLT(frame *mp3Tag) (string, string) {	// Unsychronized lyric/text transcription
-	return "", ""
+	_, text, ok := parseCOMM(frame.payload)	// same layout as COMM
+	if !ok {
+		return "Error", "Truncated USLT frame"
+	}
+	return "Lyrics", text
 }
 func doWCOM(frame *mp3Tag) (string, string) {	// Commercial information
 	return "", ""
@@ -483,44 +866,77 @@ func doWPUB(frame *mp3Tag) (string, string) {	// Publishers official webpage
 func doWXXX(frame *mp3Tag) (string, string) {	// User defined URL link frame
 	return "", ""
 }
-// Read an exact count of bytes
-func readExact(f *os.File, b []byte) error {
-	n, err := f.Read(b)
-//fmt.Printf("Read 0x%x bytes\n", n)
-	if err == nil {
-		if n != len(b) {
-			err = errors.New("Error reading MP3 file")
-		}
+
+// Return the count of extra bytes that the flags of a frame (ID3v2.3 layout)
+// require at the beginning of its payload.
+func extraSize(flags uint16) int {
+	n := 0
+	if flags & flgCompressed != 0 || flags & flgDataLength != 0 {
+		n += 4
 	}
-	return err
+	if flags & flgEncrypted != 0 {
+		n++
+	}
+	if flags & flgGroupId != 0 {
+		n++
+	}
+	return n
 }
 
-// ProcessAllTags processes all the tags of an MP3 file and saves related
-// information in an MP3Info structure returned to the caller.
-func ProcessAllTags(fname string) (*MP3Info, error) {
-	var	mi MP3Info
-	mi.AllTags = make(map[string]*ProcessedTag)
-
-	// Retrieve the header, if any.
-	sf, err := os.Open(fname)
+// Decompress the zlib data of a compressed frame. size is the decompressed
+// size announced in the frame, or 0 if unknown.
+func inflate(pl []byte, size uint) ([]byte, error) {
+	zr, err := zlib.NewReader(bytes.NewReader(pl))
 	if err != nil {
-		return nil, err
+		return nil, errors.New(fmt.Sprintf("Invalid compressed frame (%s)", err))
 	}
-	defer sf.Close()
-
-	// Make sure we have a valid ID3v2 header.
-	b := make([]byte, 10)	// ID3v2 header size
-	err = readExact(sf, b)
+	defer zr.Close()
+	if size == 0 || size > maxInflate {
+		size = maxInflate
+	}
+	d, err := io.ReadAll(io.LimitReader(zr, int64(size)))
 	if err != nil {
-		return nil, err
+		return nil, errors.New(fmt.Sprintf("Invalid compressed frame (%s)", err))
 	}
-	if b[0] != 'I' || b[1] != 'D' || b[2] != '3' {
-		fmt.Printf("%#v\n", b)
-		panic(fmt.Sprintf("NO ID3v2 HEADER IN %q", fname))
+	return d, nil
+}
+
+// FrameError describes an invalid frame, or an invalid part of an ID3v2 tag,
+// found while processing the tags of a file.
+type FrameError struct {
+	ID string		// 4-char tag, or "" if the error is not related to a frame
+	Offset int64	// file offset of the frame (offset in the resynchronised tag for unsynchronised ID3v2.3 tags)
+	Msg string
+}
+
+func (e *FrameError) Error() string {
+	if e.ID == "" {
+		return fmt.Sprintf("ID3v2 data at 0x%x: %s", e.Offset, e.Msg)
 	}
+	return fmt.Sprintf("%s frame at 0x%x: %s", e.ID, e.Offset, e.Msg)
+}
+
+// Convert ID3v2.4 frame flags to the ID3v2.3 layout.
+func v24Flags(f uint16) uint16 {
+	return (f & 0x7000) << 1 |	// abc
+		(f & 0x0040) >> 1 |		// h -> k
+		(f & 0x0008) << 4 |		// k -> i
+		(f & 0x0004) << 4 |		// m -> j
+		(f & 0x0003)			// n, p
+}
+
+// Tell if b starts with a valid ID3v2 header (id is "ID3") or footer (id is
+// "3DI").
+func validHeader(b []byte, id string) bool {
 	// 49 44 33 yy yy xx zz zz zz zz	// yy yy = version, xx = flags, zz zz zz zz = size
-	if b[3] == 0xff || b[4] == 0xff || b[5] & 0x1f != 0 || b[6] & 0x80 != 0 || b[7] & 0x80 != 0 || b[8] & 0x80 != 0 || b[9] & 0x80 != 0 {
-		return nil, errors.New("Invalid ID3v2 header")
+	return len(b) >= 10 && string(b[:3]) == id && b[3] != 0xff && b[4] != 0xff && b[5] & 0x0f == 0 && (b[3] >= 4 || b[5] & 0x10 == 0) && b[6] & 0x80 == 0 && b[7] & 0x80 == 0 && b[8] & 0x80 == 0 && b[9] & 0x80 == 0
+}
+
+// Check the 10-byte header of an ID3v2 tag and return the size of the tag,
+// header excluded.
+func tagHeader(b []byte) (uint, error) {
+	if !validHeader(b, "ID3") {
+		return 0, errors.New("Invalid ID3v2 header")
 	}
 	hdrsz := (uint(b[6]) << 21) + (uint(b[7]) << 14) + (uint(b[8]) << 7) + (uint(b[9]) << 0)
 	if Verbose >= 2 {
@@ -535,127 +951,567 @@ func ProcessAllTags(fname string) (*MP3Info, error) {
 		if b[5] & 0x20 != 0 {
 			aux += "eXprmt"
 		}
+		if b[5] & 0x10 != 0 {
+			aux += "footer"
+		}
 		if aux != "" {
 			fmt.Printf(" (%s)", aux)
 		}
 		fmt.Printf(", 0x%x bytes\n", hdrsz)
 	}
-	
+	return hdrsz, nil
+}
+
+// Return the file offset of the end of a tag starting at pos, footer
+// included.
+func tagEnd(pos int64, hflags byte, hdrsz uint) int64 {
+	end := pos + 10 + int64(hdrsz)
+	if hflags & 0x10 != 0 {	// a 10-byte footer follows the tag
+		end += 10
+	}
+	return end
+}
+
+// Tell if there is a valid ID3v2 header at offset pos of a file.
+func hasTagAt(sf io.ReaderAt, pos int64) bool {
+	b := make([]byte, 10)
+	_, err := sf.ReadAt(b, pos)
+	return err == nil && validHeader(b, "ID3")
+}
+
+// Find the first ID3v2 tag of a file. It is normally at offset 0, but some
+// files have junk before it. This returns -1 if there is no such tag.
+func findTag(sf io.ReaderAt) (int64, error) {
+	b := make([]byte, maxJunk + 10)
+	n, err := sf.ReadAt(b, 0)
+	if err != nil && err != io.EOF {
+		return -1, err
+	}
+	b = b[:n]
+	if validHeader(b, "ID3") {
+		return 0, nil
+	}
+	if len(b) >= 2 && b[0] == 0xff && b[1] & 0xe0 == 0xe0 {	// audio data starts right here
+		return -1, nil
+	}
+	for i := 1; i + 10 <= len(b); i++ {
+		j := bytes.Index(b[i:], []byte("ID3"))
+		if j == -1 {
+			break
+		}
+		i += j
+		if validHeader(b[i:], "ID3") {
+			if Verbose >= 2 {
+				fmt.Printf("0x%x bytes of junk before the ID3v2 tag\n", i)
+			}
+			return int64(i), nil
+		}
+	}
+	return -1, nil
+}
+
+// Find an ID3v2 tag appended at the end of a file, whose footer ends at
+// offset end. This returns the offset of the tag, or -1 if there is none.
+func findAppendedTag(sf io.ReaderAt, end int64) (int64, error) {
+	if end < 20 {
+		return -1, nil
+	}
+	b := make([]byte, 10)
+	_, err := sf.ReadAt(b, end - 10)
+	if err != nil {
+		return -1, err
+	}
+	if !validHeader(b, "3DI") {
+		return -1, nil
+	}
+	hdrsz := (int64(b[6]) << 21) + (int64(b[7]) << 14) + (int64(b[8]) << 7) + (int64(b[9]) << 0)
+	pos := end - 10 - hdrsz - 10
+	if pos < 0 || !hasTagAt(sf, pos) {
+		return -1, errors.New(fmt.Sprintf("No ID3v2 header matching the footer at 0x%x", end - 10))
+	}
+	return pos, nil
+}
+
+// Read and process the ID3v2 tag at offset pos of a file.
+// This returns the offset of the end of the tag, footer included, and the
+// SEEK frame of the tag, if any.
+func (mi *MP3Info) readTag(sf io.ReaderAt, size, pos int64) (int64, *TagSeek, error) {
+	b := make([]byte, 10)	// ID3v2 header size
+	_, err := sf.ReadAt(b, pos)
+	if err != nil {
+		return 0, nil, err
+	}
+	hdrsz, err := tagHeader(b)
+	if err != nil {
+		return 0, nil, err
+	}
+	tl := &TagLayout{Offset:pos, Version:b[3], Revision:b[4], Flags:b[5], Size:hdrsz}
+	if tagEnd(pos, b[5], hdrsz) > size {
+		err = &FrameError{Offset:pos, Msg:fmt.Sprintf("tag size (0x%x bytes) exceeds the file size", hdrsz)}
+		if !mi.warn(err) {
+			return 0, nil, err
+		}
+		b[5] &^= 0x10	// keep what the file holds, without a footer
+		hdrsz = uint(size - pos - 10)
+	}
+
 	// Read all the ID3v2 frames in a single buffer.
 	hb := make([]byte, hdrsz)
-	err = readExact(sf, hb)
+	_, err = sf.ReadAt(hb, pos + 10)
+	if err != nil {
+		return 0, nil, err
+	}
+	if b[5] & 0x10 != 0 {	// the footer must be a copy of the header
+		f := make([]byte, 10)
+		_, err = sf.ReadAt(f, pos + 10 + int64(hdrsz))
+		if err != nil {
+			return 0, nil, err
+		}
+		if string(f[:3]) != "3DI" || !bytes.Equal(f[3:], b[3:]) {
+			err = errors.New(fmt.Sprintf("Invalid ID3v2 footer at 0x%x", pos + 10 + int64(hdrsz)))
+			if !mi.warn(err) {
+				return 0, nil, err
+			}
+		}
+	}
+	seek, err := mi.processTag(tl, hb)
+	if err != nil {
+		return 0, nil, err
+	}
+	mi.tagPos = append(mi.tagPos, pos)
+	mi.Layouts = append(mi.Layouts, tl)
+	return tagEnd(pos, b[5], hdrsz), seek, nil
+}
+
+// Tell if the ID3v2 tag at offset pos has already been processed.
+func (mi *MP3Info) tagDone(pos int64) bool {
+	for _, p := range mi.tagPos {
+		if p == pos {
+			return true
+		}
+	}
+	return false
+}
+
+// ProcessAllTags processes all the tags of an MP3 file and saves related
+// information in an MP3Info structure returned to the caller.
+// ID3v2 tags are searched at the beginning of the file (after junk, if any,
+// and possibly several of them), where SEEK frames point to and at the end
+// of the file ("3DI" footer). Frames of later tags replace the frames of
+// earlier tags with the same ID.
+func ProcessAllTags(fname string) (*MP3Info, error) {
+	return ProcessAllTagsWith(fname, nil)
+}
+
+// ProcessAllTagsWith is like ProcessAllTags, with options (nil for the
+// defaults, the lenient mode being set by the Lenient variable).
+func ProcessAllTagsWith(fname string, opts *Options) (*MP3Info, error) {
+	// Retrieve the header, if any.
+	sf, err := os.Open(fname)
 	if err != nil {
 		return nil, err
 	}
-		
-	// Read the information word of the first data frame.
-	err = readExact(sf, b[:4])
+	defer sf.Close()
+	fi, err := sf.Stat()
 	if err != nil {
 		return nil, err
 	}
+	return ReadAllTagsWith(sf, fi.Size(), opts)
+}
+
+// ReadAllTags is like ProcessAllTags, for an MP3 file of a given size that
+// is read through an io.ReaderAt (eg, an uploaded file held in memory).
+// It never panics, even on malformed or hostile input: invalid tags and
+// frames are reported as errors, most of them being FrameError.
+func ReadAllTags(sf io.ReaderAt, size int64) (*MP3Info, error) {
+	return ReadAllTagsWith(sf, size, nil)
+}
+
+// ReadAllTagsWith is like ReadAllTags, with options (nil for the defaults).
+func ReadAllTagsWith(sf io.ReaderAt, size int64, opts *Options) (*MP3Info, error) {
+	if opts == nil {
+		opts = &Options{Lenient:Lenient}
+	}
+	mi, err := readAllTags(sf, size, opts.Charset, opts.Lenient)
+	if err != nil || opts.Charset != nil || !opts.DetectCharset {
+		return mi, err
+	}
+	cs := DetectCharset(mi.latin1)
+	if cs == nil {	// ISO-8859-1 indeed
+		return mi, nil
+	}
+	if Verbose >= 1 {
+		fmt.Printf("Charset: %s\n", cs)
+	}
+	return readAllTags(sf, size, cs, opts.Lenient)	// again, with the right charset
+}
+
+// Process all the tags of a file, decoding the ISO-8859-1 strings with a
+// given charset (nil for ISO-8859-1), in lenient mode or not.
+func readAllTags(sf io.ReaderAt, size int64, cs encoding.Encoding, lenient bool) (*MP3Info, error) {
+	var	mi MP3Info
+	mi.AllTags = make(map[string]*ProcessedTag)
+	mi.Charset = cs
+	mi.lenient = lenient
+
+	// Process the ID3v2 tags at the beginning of the file. The audio data
+	// follows the last one.
+	pos, err := findTag(sf)
+	if err != nil {
+		return nil, err
+	}
+	var audio int64	// offset of the first data frame
+	var seek *TagSeek
+	var seekEnd int64	// end of the tag of the SEEK frame
+	for pos >= 0 && !mi.tagDone(pos) {
+		end, ts, err := mi.readTag(sf, size, pos)
+		if err != nil {
+			if !mi.warn(err) {
+				return nil, err
+			}
+			break
+		}
+		audio = end
+		if ts != nil {
+			seek, seekEnd = ts, end
+		}
+		pos = -1
+		if hasTagAt(sf, audio) {	// another tag follows
+			pos = audio
+		}
+	}
+	if Verbose >= 2 && len(mi.tagPos) == 0 {
+		fmt.Println("No ID3v2 header")
+	}
+	mi.AudioStart = audio
+	
+	// Read the information word of the first data frame, if any (the file
+	// may hold nothing but tags).
+	b := make([]byte, 4)
+	_, err = sf.ReadAt(b, audio)
+	short := err == io.EOF || err == io.ErrUnexpectedEOF
+	if err != nil && !short {
+		return nil, err
+	}
 	var info uint32 = uint32(b[0]) << 24 | uint32(b[1]) << 16  | uint32(b[2]) << 8  | uint32(b[3])
-	if info & 0xffe00000 != 0xffe00000 {	// invalid synch pattern?!?
-//		err = errors.New(fmt.Sprintf("Invalid sync pattern (info = 0x%04X)", info))
-//		return nil, err
-		fmt.Printf("Invalid sync pattern (info = 0x%04X)\n", info)
-//		mi.BitRate = 0
-	} else {	// looks like a valid sync pattern
+	switch {
+	case short:	// no data frame: unknown bitrate
+		if Verbose >= 1 {
+			fmt.Println("No data frame")
+		}
+	case info & 0xffe00000 != 0xffe00000 || info & 0x00060000 == 0:	// invalid synch pattern (or reserved layer)?!?
+		if Verbose >= 1 {
+			fmt.Printf("Invalid sync pattern (info = 0x%04X)\n", info)
+		}
+	default:	// looks like a valid sync pattern
 		// Compute the bitrate from the indexes in the info word.
 		vi  := (info & 0x00180000) >> 19	// MPEG version (00: V2.5, 01: reserved, 10: V2, 11: V1)
 		li  := (info & 0x00060000) >> 17	// Layer (00: reserved, 01: L3, 10: L2, 11: L1)
 		bri := (info & 0x0000f000) >> 12	// bitrate index
-//fmt.Printf("info = 0x%04X (vi = %d, li = %d, bri = %d)\n", info, vi, li, bri)
 		mi.BitRate = bitRateTable[vi & 1][li - 1][bri]
 	}
 	if Verbose >= 1 {
 		fmt.Printf("Bitrate = %d kbps\n", mi.BitRate)
 	}
 
+	// Process the additional tags SEEK frames may point to, the offset of a
+	// SEEK frame being relative to the end of its tag.
+	end := seekEnd
+	for n := 0; seek != nil && n < maxSeek; n++ {
+		pos = end + int64(seek.Offset)
+		if Verbose >= 2 {
+			fmt.Printf("SEEK to 0x%x\n", pos)
+		}
+		if !hasTagAt(sf, pos) || mi.tagDone(pos) {	// stop here
+			if Verbose >= 1 {
+				fmt.Printf("No ID3v2 tag at SEEK offset 0x%x\n", pos)
+			}
+			break
+		}
+		end, seek, err = mi.readTag(sf, size, pos)
+		if err != nil {
+			if !mi.warn(err) {
+				return nil, err
+			}
+			break
+		}
+	}
+
+	// Read the ID3v1 tag, if any, and the APE, Lyrics3v2 and appended ID3v2
+	// tags that may precede it.
+	mi.ID3v1, err = readID3v1(sf, size, cs)
+	if err != nil {
+		return nil, err
+	}
+	if mi.ID3v1 != nil {
+		mi.latin1 = append(mi.latin1, mi.ID3v1.raw...)
+	}
+	end = size
+	if mi.ID3v1 != nil {
+		end -= mi.ID3v1.Size()
+	}
+	err = mi.readAppendedTags(sf, size, end)
+	if err != nil && !mi.warn(err) {
+		return nil, err
+	}
+	mi.mergeID3v1()
+
+	// Link encrypted and grouped tags to their registrations, which may
+	// follow them in the tag.
+	linkRegistrations(mi.Frames)
+	return &mi, nil
+}
+
+// Process the extended header, if any, and all the frames of an ID3v2 tag.
+// tl is the layout of the tag, as known from its header, and hb the rest
+// of the tag. The layout of the frames is added to tl.
+// This returns the SEEK frame of the tag, if any.
+func (mi *MP3Info) processTag(tl *TagLayout, hb []byte) (seek *TagSeek, err error) {
+	var frames []*ProcessedTag
 	var ihb uint = 0		// start here
+	pos, ver, hflags := tl.Offset, tl.Version, tl.Flags
+
+	// In ID3v2.3 tags, unsynchronisation applies to the whole tag, extended
+	// header included. It applies to each frame in ID3v2.4 tags.
+	if ver < 4 && hflags & 0x80 != 0 {
+		hb = deunsync(hb)
+	}
+	hdrsz := uint(len(hb))
+
+	// In ID3v2.2 tags, this flag tells the tag is compressed, with no
+	// compression scheme defined yet.
+	if ver < 3 && hflags & 0x40 != 0 {
+		fe := &FrameError{Offset:pos, Msg:"compressed ID3v2.2 tag"}
+		if !mi.warn(fe) {
+			return nil, fe
+		}
+		tl.Used = hdrsz
+		return nil, nil
+	}
 
 	// Check for an extended header.
-	if b[5] & 0x40 != 0	{ // an extended header follows
-		var xsiz, xflg, xcrc, xpad uint
-		xsiz = (uint(hb[0]) << 24) + (uint(hb[1]) << 16) + (uint(hb[2]) << 8) + (uint(hb[3]) << 0)
-		xflg = uint((uint16(hb[4]) << 8) + (uint16(hb[5]) << 0))
-		if Verbose >= 2 {
-			xpad = (uint(hb[6]) << 24) + (uint(hb[7]) << 16) + (uint(hb[8]) << 8) + (uint(hb[9]) << 0)
+	if hflags & 0x40 != 0	{ // an extended header follows
+		xh, xsiz, err := parseExtHeader(ver, hb)
+		if err != nil {
+			fe := &FrameError{Offset:pos, Msg:err.Error()}
+			if !mi.warn(fe) {
+				return nil, fe
+			}
+			xsiz = resync(ver, hb, 0)	// seek the first frame
 		}
-		ihb += 10	// skip the extended header size
-		if xflg & 0x7fff != 0 {
-			return nil, errors.New(fmt.Sprintf("Invalid flags for extended tag (0x%x)", xflg))
+		ihb = xsiz	// seek the first tag
+		tl.Used = xsiz
+		tl.ExtendedHeader = xh
+		if mi.ExtendedHeader == nil {
+			mi.ExtendedHeader = xh
 		}
-		if xflg & 0x8000 != 0 {	// CRC data present
-			if Verbose >= 2 {
-				xcrc = (uint(hb[10]) << 24) + (uint(hb[11]) << 16) + (uint(hb[12]) << 8) + (uint(hb[13]) << 0)
-			}
-			ihb += 4	// skip the CRC
+		if xh != nil && xh.HasCRC && !xh.CRCValid {	// reported, the frames are still read
+			mi.BadCRC = true
+			mi.Warnings = append(mi.Warnings, &FrameError{Offset:pos, Msg:fmt.Sprintf("CRC mismatch (0x%08x)", xh.CRC)})
 		}
-		ihb += xsiz	// seek the first tag
-		if Verbose >= 2 {
-			fmt.Printf(" * Extended header: length = 0x%08x, flags = 0x%x, padding = 0x%08x", xsiz, xflg, xpad)
-			if xflg & 0x8000 != 0 {	// CRC data present
-				fmt.Printf(", CRC = 0x%x", xcrc)
+		if Verbose >= 2 && xh != nil {
+			fmt.Printf(" * Extended header: length = 0x%08x, padding = 0x%08x, update = %t", xh.Size, xh.Padding, xh.Update)
+			if xh.HasCRC {
+				fmt.Printf(", CRC = 0x%x (valid = %t)", xh.CRC, xh.CRCValid)
+			}
+			if xh.Restrictions != nil {
+				fmt.Printf(", restrictions = %s", xh.Restrictions)
 			}
 			fmt.Println()
 		}
 	}
 		
 	// Process the tags until the big header is consumed.
-	for ;ihb < hdrsz; {
-//		ihb0 := ihb	// save beginning of the tag
+	fhs := frameHeaderSize(ver)
+	for ;ihb + fhs <= hdrsz; {
+		ihb0 := ihb	// save beginning of the tag
 		
-		// Slice the tag header. Reuse b.
-		b = hb[ihb:ihb + 10]
-		ihb += 10
+		// Slice the tag header.
+		fpos := pos + 10 + int64(ihb)	// file offset of the frame
+		b := hb[ihb:ihb + fhs]
+		ihb += fhs
 		
 		var t mp3Tag
-		t.tag = string(b[:4])
-		t.size = (uint(b[4]) << 24) + (uint(b[5]) << 16) + (uint(b[6]) << 8) + (uint(b[7]) << 0)
-		t.flags = (uint16(b[8]) << 8) + (uint16(b[9]) << 0)
-		if t.flags & 0x1f1f != 0 {
-			return nil, errors.New(fmt.Sprintf("Invalid flags for tag %s (0x%x)", t.tag, t.flags))
+		t.version = ver
+		t.charset = mi.Charset
+		id := b[:4]
+		if ver < 3 {	// 3-character IDs
+			id = b[:3]
+		} else {
+			t.rawFlags = (uint16(b[8]) << 8) + (uint16(b[9]) << 0)
 		}
-		if t.tag == "" || t.size == 0 {
-			break
+		t.tag = string(id)
+		if mi.lenient && !validFrameID(id) {	// padding, or junk
+			if isPadding(hb[ihb0:]) {
+				break
+			}
+			ihb = resync(ver, hb, ihb0 + 1)
+			mi.warn(&FrameError{Offset:fpos, Msg:fmt.Sprintf("0x%x bytes of junk skipped", ihb - ihb0)})
+			continue
 		}
-		
-		// Read the extra bytes following the tag header, if any.
-		if (t.flags & flgCompressed) != 0 {
-			b = hb[ihb:ihb + 4]
-			ihb += 4
-			t.extra.uncSize = (uint(b[0]) << 24) + (uint(b[1]) << 16) + (uint(b[2]) << 8) + (uint(b[3]) << 0)
+		if ver < 3 {	// no flags
+			t.size = (uint(b[3]) << 16) + (uint(b[4]) << 8) + (uint(b[5]) << 0)
+		} else if ver >= 4 {
+			t.size = syncsafe(b[4:8])
+			t.flags = v24Flags(t.rawFlags)
+			if mi.lenient {
+				var plain bool
+				if t.size, plain = frameSize24(hb, ihb0); plain {
+					mi.warn(&FrameError{ID:t.tag, Offset:fpos, Msg:"non-syncsafe frame size"})
+				}
+			}
+			if t.rawFlags & 0x8fb0 != 0 {
+				fe := &FrameError{ID:t.tag, Offset:fpos, Msg:fmt.Sprintf("invalid flags (0x%x)", t.rawFlags)}
+				if !mi.warn(fe) {
+					return nil, fe
+				}
+				t.flags = v24Flags(t.rawFlags &^ 0x8fb0)
+			}
+		} else {
+			t.size = (uint(b[4]) << 24) + (uint(b[5]) << 16) + (uint(b[6]) << 8) + (uint(b[7]) << 0)
+			t.flags = t.rawFlags
+			if t.flags & 0x1f1f != 0 {
+				fe := &FrameError{ID:t.tag, Offset:fpos, Msg:fmt.Sprintf("invalid flags (0x%x)", t.flags)}
+				if !mi.warn(fe) {
+					return nil, fe
+				}
+				t.flags &^= 0x1f1f
+			}
 		}
-		if (t.flags & flgEncrypted) != 0 {
-			t.extra.encType = hb[ihb]
-			ihb += 1
+		if t.tag == "" || t.size == 0 {
+			if mi.lenient && t.size == 0 {	// empty frame
+				mi.warn(&FrameError{ID:t.tag, Offset:fpos, Msg:"empty frame"})
+				continue
+			}
+			break
 		}
-		if (t.flags & flgGroupId) != 0 {
-			t.extra.groupID = hb[ihb]
-			ihb += 1
+		if t.size > hdrsz - ihb {
+			fe := &FrameError{ID:t.tag, Offset:fpos, Msg:fmt.Sprintf("frame size (0x%x bytes) exceeds the tag size", t.size)}
+			if !mi.warn(fe) {
+				return nil, fe
+			}
+			t.size = hdrsz - ihb
 		}
 		
-		// Slice the payload.
+		// Slice the payload, then the extra bytes at its beginning, if any.
 		t.payload = hb[ihb:ihb + t.size]
 		ihb += t.size
+		tl.Used = ihb
+		tl.Frames = append(tl.Frames, FrameLayout{ID:t.tag, Offset:fpos, Size:t.size, Flags:t.rawFlags})
+		if ver < 3 {	// ID3v2.2 frames are processed as their ID3v2.3 counterparts
+			if id, ok := v22IDs[t.tag]; ok {
+				t.tag = id
+				if id == "APIC" {
+					t.payload = picToAPIC(t.payload)
+				}
+			}
+		}
+		if uint(extraSize(t.flags)) > t.size {
+			fe := &FrameError{ID:t.tag, Offset:fpos, Msg:fmt.Sprintf("frame too short (0x%x bytes) for its flags (0x%x)", t.size, t.rawFlags)}
+			if !mi.warn(fe) {
+				return nil, fe
+			}
+			continue
+		}
+		if ver >= 4 {	// group ID, encryption type and data length
+			if (t.flags & flgGroupId) != 0 {
+				t.extra.groupID = t.payload[0]
+				t.payload = t.payload[1:]
+			}
+			if (t.flags & flgEncrypted) != 0 {
+				t.extra.encType = t.payload[0]
+				t.payload = t.payload[1:]
+			}
+			if (t.flags & flgDataLength) != 0 {
+				t.extra.uncSize = syncsafe(t.payload)
+				t.payload = t.payload[4:]
+			}
+			if (t.flags & flgUnsync) != 0 || hflags & 0x80 != 0 {
+				t.payload = deunsync(t.payload)
+			}
+		} else {	// uncompressed size, encryption type and group ID
+			if (t.flags & flgCompressed) != 0 {
+				b = t.payload[:4]
+				t.payload = t.payload[4:]
+				t.extra.uncSize = (uint(b[0]) << 24) + (uint(b[1]) << 16) + (uint(b[2]) << 8) + (uint(b[3]) << 0)
+			}
+			if (t.flags & flgEncrypted) != 0 {
+				t.extra.encType = t.payload[0]
+				t.payload = t.payload[1:]
+			}
+			if (t.flags & flgGroupId) != 0 {
+				t.extra.groupID = t.payload[0]
+				t.payload = t.payload[1:]
+			}
+		}
 
 		if Verbose >= 2 {
 			fmt.Printf("  %s: length = 0x%07x, flags = 0x%x\n", t.tag, t.size, t.flags)
 		}
+		if t.flags & flgCompressed != 0 && t.flags & flgEncrypted == 0 {
+			pl, err := inflate(t.payload, t.extra.uncSize)
+			if err != nil {
+				fe := &FrameError{ID:t.tag, Offset:fpos, Msg:err.Error()}
+				if !mi.warn(fe) {
+					return nil, fe
+				}
+				continue
+			}
+			t.payload = pl
+		}
 
 		// Extract tag's value.
 		tfn, ok := tagmap[t.tag]
-		if !ok {
-			fmt.Printf("%s: UNEXPECTED TAG!\n", t.tag)
-			continue
+		var lbl, val string
+		if t.flags & flgEncrypted != 0 {	// the payload cannot be decoded
+			lbl, val = "Encrypted", fmt.Sprintf("%d bytes, method 0x%02x", len(t.payload), t.extra.encType)
+		} else if !ok {	// kept as is, to be written back
+			lbl, val = "Unknown", fmt.Sprintf("%d bytes", len(t.payload))
+		} else {
+			lbl, val = tfn(&t)
 		}
-		lbl, val := tfn(&t)
 		if Verbose >= 1 {
 			fmt.Printf("%s: %s\n", lbl, val)
 		}
-		mi.AllTags[t.tag] = &ProcessedTag{Name:lbl, Value:val}
+		mi.latin1 = append(mi.latin1, t.latin1...)
+		pt := &ProcessedTag{Name:lbl, Value:val, Data:t.data, ID:t.tag, Flags:t.flags, EncMethod:t.extra.encType, GroupID:t.extra.groupID, Values:t.values, Payload:t.payload, uncSize:t.extra.uncSize, tag:tl}
+		if lbl == "Error" {
+			pt.Err = &FrameError{ID:t.tag, Offset:fpos, Msg:val}
+		} else if t.err != nil {
+			pt.Err = &FrameError{ID:t.tag, Offset:fpos, Msg:t.err.Error()}
+		}
+		if pt.Err != nil {
+			mi.Warnings = append(mi.Warnings, pt.Err)
+		}
+		frames = append(frames, pt)
+		if ts, ok := t.data.(*TagSeek); ok {
+			seek = ts
+		}
+	}
+	tl.Padding = hdrsz - tl.Used
+	mi.mergeFrames(frames)
+	return seek, nil
+}
+
+// Merge the frames of a new ID3v2 tag with the frames of the tags
+// previously processed: the frames of the new tag replace all the previous
+// frames with the same ID.
+func (mi *MP3Info) mergeFrames(frames []*ProcessedTag) {
+	ids := make(map[string]bool)
+	for _, pt := range frames {
+		ids[pt.ID] = true
+	}
+	kept := mi.Frames[:0]
+	for _, pt := range mi.Frames {
+		if pt.Origin != OriginID3v2 || !ids[pt.ID] {
+			kept = append(kept, pt)
+		}
+	}
+	mi.Frames = kept
+	for _, pt := range frames {
+		mi.AllTags[pt.ID] = pt
+		mi.Frames = append(mi.Frames, pt)
 	}
-	return &mi, nil
 }
 
 // Parse an int value that can be terminated by a non-digit character.
@@ -0,0 +1,257 @@
+package id3v2
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// Return an ID3v2.2 tag made of frames of 3-character IDs and payloads.
+func tag22(frames ...string) []byte {
+	var body []byte
+	for i := 0; i+1 < len(frames); i += 2 {
+		n := len(frames[i+1])
+		body = append(body, frames[i]...)
+		body = append(body, byte(n>>16), byte(n>>8), byte(n))
+		body = append(body, frames[i+1]...)
+	}
+	body = append(body, make([]byte, 16)...) // padding
+	return append([]byte{'I', 'D', '3', 2, 0, 0}, append(syncsafeBytes(uint(len(body))), body...)...)
+}
+
+// Return an encoded ID3v2.3 or ID3v2.4 tag holding a few common frames.
+func encodedTag(tb testing.TB, ver byte, unsync, crc bool) []byte {
+	t := &Tag{Version: ver, Padding: 32, Unsync: unsync, CRC: crc}
+	t.SetTitle("Title")
+	t.SetArtist("Artist 1", "Artist 2")
+	t.SetAlbum("Ålbum")
+	t.SetTrack(3, 12)
+	t.SetYear(2001)
+	t.AddComment("eng", "", "A comment")
+	t.SetRating("me@example.com", 200)
+	t.AddPicture(&Picture{MIMEType: "image/png", Type: PictureFrontCover, Data: []byte("\x89PNG\r\n\x1a\n\xff\x00")})
+	b, err := t.Encode()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return b
+}
+
+// The MPEG header of a 128 kbps, 44.1 kHz MPEG-1 layer III frame.
+var mpegFrame = []byte{0xff, 0xfb, 0x90, 0x00}
+
+func TestReadAllTagsWithoutAudio(t *testing.T) {
+	for _, ver := range []byte{3, 4} {
+		b := encodedTag(t, ver, false, false)
+		mi, err := ReadAllTags(bytes.NewReader(b), int64(len(b)))
+		if err != nil {
+			t.Fatalf("ID3v2.%d: %s", ver, err)
+		}
+		if pt := mi.AllTags["TIT2"]; pt == nil || pt.Value != "Title" {
+			t.Errorf("ID3v2.%d: title %v", ver, pt)
+		}
+		if mi.BitRate != 0 || mi.AudioStart != int64(len(b)) {
+			t.Errorf("ID3v2.%d: bitrate %d, audio at %d", ver, mi.BitRate, mi.AudioStart)
+		}
+	}
+}
+
+func TestLenientOption(t *testing.T) {
+	// A TIT2 frame whose size overruns the tag.
+	b := []byte("ID3\x03\x00\x00\x00\x00\x00\x10TIT2\x00\x00\x01\x00\x00\x00\x00Title")
+	if _, err := ReadAllTagsWith(bytes.NewReader(b), int64(len(b)), &Options{}); err == nil {
+		t.Error("broken tag read in strict mode")
+	}
+	mi, err := ReadAllTagsWith(bytes.NewReader(b), int64(len(b)), &Options{Lenient: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pt := mi.AllTags["TIT2"]; pt == nil || pt.Value != "Title" || len(mi.Warnings) == 0 {
+		t.Errorf("title %v, warnings %v", pt, mi.Warnings)
+	}
+}
+
+func FuzzReadAllTags(f *testing.F) {
+	f.Add(tag22("TT2", "\x00Title", "TP1", "\x01\xff\xfeA\x00", "COM", "\x00engdesc\x00text", "PIC", "\x00PNG\x03\x00\x89PNG"))
+	f.Add(encodedTag(f, 3, false, false))
+	f.Add(encodedTag(f, 3, true, true))
+	f.Add(encodedTag(f, 4, false, false))
+	f.Add(append(encodedTag(f, 4, true, true), mpegFrame...))
+	f.Add([]byte("ID3\x04\x00\x00\x00\x00\x00\x30MCDI\x00\x00\x00\x0c\x00\x00\x00\x0c\x01\x01\x00\x14\x01\x00\x00\x00\x00\x00MLLT\x00\x00\x00\x0a\x00\x00\x00\x01\x00\x00\x01\x00\x00\x02\x04\x04\x00"))
+	f.Fuzz(func(t *testing.T, b []byte) {
+		for _, lenient := range []bool{false, true} {
+			mi, err := ReadAllTagsWith(bytes.NewReader(b), int64(len(b)), &Options{Lenient: lenient})
+			if err == nil && mi == nil {
+				t.Fatal("no error and no information")
+			}
+		}
+	})
+}
+
+func FuzzFrameDecoders(f *testing.F) {
+	f.Add(byte(3), []byte("\x00Title"))
+	f.Add(byte(4), []byte("\x03A\x00B"))
+	f.Add(byte(3), []byte("\x01\xff\xfeA\x00\x00\x00"))
+	f.Add(byte(3), []byte("\x00engdesc\x00text"))
+	f.Add(byte(3), []byte("\x00image/png\x00\x03desc\x00\x89PNG"))
+	f.Add(byte(3), []byte("me@example.com\x00\xc8\x00\x00\x00\x01"))
+	f.Add(byte(4), []byte("\x00\x01\x00\x00\x01\x00\x00\x02\x04\x04\x00"))
+	f.Fuzz(func(t *testing.T, ver byte, pl []byte) {
+		for id, tfn := range tagmap {
+			frame := &mp3Tag{version: ver, tag: id, size: uint(len(pl)), payload: pl}
+			tfn(frame)
+		}
+	})
+}
+
+// Encode a tag made of frames (ID and payload) and read it back.
+func readFrames(tb testing.TB, ver byte, frames ...*ProcessedTag) *MP3Info {
+	tag := &Tag{Version: ver, Frames: frames}
+	b, err := tag.Encode()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	mi, err := ReadAllTags(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return mi
+}
+
+// Return a frame made of an ID and a payload.
+func rawFrame(id string, pl []byte) *ProcessedTag {
+	return &ProcessedTag{ID: id, Payload: pl, Origin: OriginID3v2}
+}
+
+// Encode an ID3v2 tag made of frames.
+func encodeFrames(tb testing.TB, ver byte, footer bool, frames ...*ProcessedTag) []byte {
+	b, err := (&Tag{Version: ver, Footer: footer, Frames: frames}).Encode()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return b
+}
+
+func TestFindTags(t *testing.T) {
+	title := func(s string) *ProcessedTag { return newTextFrame("TIT2", "Title", s) }
+	artist := newTextFrame("TPE1", "Artist(s)", "Artist")
+	seek := func(n int) *ProcessedTag {
+		return rawFrame("SEEK", []byte{byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)})
+	}
+	audio := append(append([]byte{}, mpegFrame...), make([]byte, 400)...)
+	t1, t2 := encodeFrames(t, 3, false, title("First"), artist), encodeFrames(t, 4, false, title("Second"))
+	withSeek := encodeFrames(t, 4, false, seek(len(t1)+100))
+	appended := encodeFrames(t, 4, true, title("Appended"))
+	cat := func(bs ...[]byte) []byte { return bytes.Join(bs, nil) }
+	for _, tc := range []struct {
+		name    string
+		file    []byte
+		offsets []int64 // offsets of the tags, in reading order
+		audio   int64
+		title   string
+	}{
+		{"junk", cat([]byte("RIFF junk\x00ID3"), t1, audio), []int64{13}, int64(13 + len(t1)), "First"},
+		{"concatenated", cat(t1, t2, audio), []int64{0, int64(len(t1))}, int64(len(t1) + len(t2)), "Second"},
+		// The SEEK offset is relative to the end of the tag of the frame,
+		// not to the end of the last tag at the beginning of the file.
+		{"SEEK", cat(withSeek, t1, audio[:100], t2, audio[100:]),
+			[]int64{0, int64(len(withSeek)), int64(len(withSeek) + len(t1) + 100)}, int64(len(withSeek) + len(t1)), "Second"},
+		{"appended", cat(t1, audio, appended), []int64{0, int64(len(t1) + len(audio))}, int64(len(t1)), "Appended"},
+		{"appended before ID3v1", cat(audio, appended, v1Tag("v1 title", "", "", "", "", 0, 255)), []int64{int64(len(audio))}, 0, "Appended"},
+	} {
+		mi, err := ReadAllTags(bytes.NewReader(tc.file), int64(len(tc.file)))
+		if err != nil {
+			t.Fatalf("%s: %s", tc.name, err)
+		}
+		var offsets []int64
+		for _, tl := range mi.Layouts {
+			offsets = append(offsets, tl.Offset)
+			if tl.Appended != tl.HasFooter() { // only the appended tags have one
+				t.Errorf("%s: tag at %d appended %v", tc.name, tl.Offset, tl.Appended)
+			}
+		}
+		if !reflect.DeepEqual(offsets, tc.offsets) {
+			t.Errorf("%s: tags at %v, want %v", tc.name, offsets, tc.offsets)
+		}
+		if mi.AudioStart != tc.audio || mi.BitRate != 128 {
+			t.Errorf("%s: audio at %d (bitrate %d), want %d", tc.name, mi.AudioStart, mi.BitRate, tc.audio)
+		}
+		if pt := mi.AllTags["TIT2"]; pt == nil || pt.Value != tc.title {
+			t.Errorf("%s: TIT2 %+v, want %q", tc.name, pt, tc.title)
+		}
+		if pt := mi.AllTags["TPE1"]; bytes.Contains(tc.file, t1) && (pt == nil || pt.Value != "Artist") {
+			t.Errorf("%s: TPE1 %+v", tc.name, pt)
+		}
+	}
+}
+
+// Return an ID3v2.3 or ID3v2.4 tag made of frames of IDs and payloads.
+func rawTag(ver byte, frames ...string) []byte {
+	var body []byte
+	for i := 0; i+1 < len(frames); i += 2 {
+		n := uint(len(frames[i+1]))
+		body = append(body, frames[i]...)
+		if ver >= 4 {
+			body = append(body, syncsafeBytes(n)...)
+		} else {
+			body = append(body, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+		}
+		body = append(body, 0, 0)
+		body = append(body, frames[i+1]...)
+	}
+	return append([]byte{'I', 'D', '3', ver, 0, 0}, append(syncsafeBytes(uint(len(body))), body...)...)
+}
+
+func TestTextFrames(t *testing.T) {
+	for _, tc := range []struct {
+		ver     byte
+		id, pl  string
+		want    []string
+		invalid bool
+	}{
+		{2, "TT2", "\x00Title\x00junk", []string{"Title"}, false},
+		{3, "TIT2", "\x00Title\x00junk", []string{"Title"}, false},
+		{3, "TIT2", "\x01\xff\xfeT\x00i\x00\x00\x00j\x00", []string{"Ti"}, false},
+		{3, "TPE1", "\x00A1/A2", []string{"A1/A2"}, false},
+		{3, "TXXX", "\x00desc\x00value\x00junk", []string{"desc", "value"}, false},
+		{3, "IPLS", "\x00role\x00name\x00", []string{"role", "name"}, false},
+		{4, "TPE1", "\x03A1\x00A2\x00", []string{"A1", "A2"}, false},
+		{4, "TXXX", "\x00desc\x00v1\x00v2", []string{"desc", "v1", "v2"}, false},
+		{3, "TIT2", "\x05Title", nil, true},
+		{4, "TIT2", "\x01\xff\xfeT", []string{"T"}, true},
+	} {
+		var b []byte
+		if tc.ver == 2 {
+			b = tag22(tc.id, tc.pl)
+		} else {
+			b = rawTag(tc.ver, tc.id, tc.pl)
+		}
+		mi, err := ReadAllTags(bytes.NewReader(b), int64(len(b)))
+		if err != nil {
+			t.Fatalf("ID3v2.%d %s %q: %s", tc.ver, tc.id, tc.pl, err)
+		}
+		id := tc.id
+		if tc.ver == 2 {
+			id = v22IDs[id]
+		}
+		pt := mi.AllTags[id]
+		if pt == nil || (pt.Err != nil) != tc.invalid || !tc.invalid && !reflect.DeepEqual(pt.Values, tc.want) {
+			t.Errorf("ID3v2.%d %s %q: %+v, want %q", tc.ver, tc.id, tc.pl, pt, tc.want)
+			continue
+		}
+
+		// Invalid frames are written back as is.
+		if !tc.invalid {
+			continue
+		}
+		tag := mi.Tag()
+		tag.Padding = 0
+		e, err := tag.Encode()
+		if err != nil {
+			t.Fatalf("ID3v2.%d %s %q: %s", tc.ver, tc.id, tc.pl, err)
+		}
+		if !bytes.Equal(e, b) {
+			t.Errorf("ID3v2.%d %s %q: written as %q", tc.ver, tc.id, tc.pl, e)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package id3v2
+
+// TagLayout describes where an ID3v2 tag and its frames lie in a file.
+// For ID3v2.3 tags with the unsynchronisation flag, sizes and offsets
+// inside the tag are those of the resynchronised tag.
+type TagLayout struct {
+	Offset   int64 // file offset of the tag header
+	Version  byte  // major version (3 for ID3v2.3.0)
+	Revision byte  // revision number
+	Flags    byte  // header flags (%abcd0000)
+	Size     uint  // declared size of the tag, header and footer excluded
+	Used     uint  // bytes used by the extended header and the frames
+	Padding  uint  // bytes following the last frame
+	Appended bool  // the tag was found at the end of the file
+	Frames   []FrameLayout
+
+	ExtendedHeader *ExtendedHeader // extended header of the tag, if any
+}
+
+// FrameLayout describes where a frame lies in a file.
+type FrameLayout struct {
+	ID     string // 4-char tag (3-char in ID3v2.2 tags)
+	Offset int64  // file offset of the frame header
+	Size   uint   // size of the frame, header excluded
+	Flags  uint16 // flags, as found in the frame header
+}
+
+// HasFooter tells if the tag ends with a footer.
+func (tl *TagLayout) HasFooter() bool {
+	return tl.Flags&0x10 != 0
+}
+
+// End returns the file offset of the end of the tag, footer included.
+func (tl *TagLayout) End() int64 {
+	return tagEnd(tl.Offset, tl.Flags, tl.Size)
+}
+
+// CRCValid tells if the tag holds a CRC-32 in its extended header, and if
+// this CRC matches the data of the tag.
+func (tl *TagLayout) CRCValid() (checked, valid bool) {
+	if xh := tl.ExtendedHeader; xh != nil && xh.HasCRC {
+		return true, xh.CRCValid
+	}
+	return false, false
+}
@@ -0,0 +1,72 @@
+package id3v2
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestTagLayout(t *testing.T) {
+	junk := []byte("junk")
+	for _, tc := range []struct {
+		name    string
+		tag     []byte
+		ver     byte
+		frames  []FrameLayout // offsets relative to the tag
+		padding uint
+	}{
+		{"ID3v2.2", tag22("TT2", "\x00Title", "TP1", "\x00Artist"), 2,
+			[]FrameLayout{{"TT2", 10, 6, 0}, {"TP1", 22, 7, 0}}, 16},
+		{"ID3v2.3", func() []byte {
+			b, err := (&Tag{Version: 3, Padding: 50, Frames: []*ProcessedTag{
+				newTextFrame("TIT2", "Title", "Title"),
+				rawFrame("PRIV", []byte("owner\x00data")),
+			}}).Encode()
+			if err != nil {
+				t.Fatal(err)
+			}
+			return b
+		}(), 3, []FrameLayout{{"TIT2", 10, 6, 0}, {"PRIV", 26, 10, 0}}, 50},
+		{"ID3v2.4", func() []byte {
+			grouped := newTextFrame("TPE1", "Artist(s)", "Artist")
+			grouped.Flags, grouped.GroupID = flgGroupId, 0x80
+			b, err := (&Tag{Version: 4, Padding: 10, Frames: []*ProcessedTag{
+				newTextFrame("TIT2", "Title", "Title"),
+				grouped,
+			}}).Encode()
+			if err != nil {
+				t.Fatal(err)
+			}
+			return b
+		}(), 4, []FrameLayout{{"TIT2", 10, 6, 0}, {"TPE1", 26, 8, 0x0040}}, 10},
+	} {
+		b := append(append(append([]byte{}, junk...), tc.tag...), mpegFrame...)
+		mi, err := ReadAllTags(bytes.NewReader(b), int64(len(b)))
+		if err != nil {
+			t.Fatalf("%s: %s", tc.name, err)
+		}
+		if len(mi.Layouts) != 1 {
+			t.Fatalf("%s: %d tags", tc.name, len(mi.Layouts))
+		}
+		tl, pos := mi.Layouts[0], int64(len(junk))
+		size := uint(len(tc.tag) - 10)
+		if tl.Offset != pos || tl.Version != tc.ver || tl.Revision != 0 || tl.Flags != 0 || tl.Size != size {
+			t.Errorf("%s: offset %d, version %d.%d, flags 0x%02x, size %d", tc.name, tl.Offset, tl.Version, tl.Revision, tl.Flags, tl.Size)
+		}
+		if tl.Used+tl.Padding != size || tl.Padding != tc.padding || tl.ExtendedHeader != nil {
+			t.Errorf("%s: used %d, padding %d, extended header %+v", tc.name, tl.Used, tl.Padding, tl.ExtendedHeader)
+		}
+		for i := range tc.frames {
+			tc.frames[i].Offset += pos
+		}
+		if !reflect.DeepEqual(tl.Frames, tc.frames) {
+			t.Errorf("%s: frames %+v, want %+v", tc.name, tl.Frames, tc.frames)
+		}
+		if tl.End() != pos+int64(len(tc.tag)) || mi.AudioStart != tl.End() || tl.HasFooter() {
+			t.Errorf("%s: end %d, audio at %d", tc.name, tl.End(), mi.AudioStart)
+		}
+		if checked, _ := tl.CRCValid(); checked {
+			t.Errorf("%s: CRC checked", tc.name)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package id3v2
+
+import (
+	"fmt"
+)
+
+// The lenient mode recovers from the errors of broken tags, as produced by
+// buggy taggers, rather than failing. It is set by the Lenient field of the
+// Options of a read, or by the Lenient variable when there are no options
+// (eg, for ProcessAllTags and ReadAllTags). In lenient mode:
+//   - the frame sizes of ID3v2.4 tags may be written as plain (non-syncsafe)
+//     integers, as in ID3v2.3 tags, which is detected from the position of the
+//     next frame;
+//   - a frame size that overruns the tag is cut to the end of the tag;
+//   - unknown frame flags are ignored;
+//   - junk between frames, or in the padding, is skipped up to the next valid
+//     frame, if any;
+//   - invalid extended headers, truncated tags and invalid trailing tags are
+//     ignored.
+//
+// The problems recovered from are reported in MP3Info.Warnings.
+// As Lenient is shared by all the reads, the reads that run concurrently
+// should rather set it in their Options.
+var Lenient bool
+
+// Record a recoverable error in the warnings, if the lenient mode is set.
+// This tells if the error has been recorded (the processing can go on).
+func (mi *MP3Info) warn(err error) bool {
+	if !mi.lenient {
+		return false
+	}
+	if Verbose >= 1 {
+		fmt.Printf("Warning: %s\n", err)
+	}
+	mi.Warnings = append(mi.Warnings, err)
+	return true
+}
+
+// Tell if id is a valid frame ID (characters in A-Z, 0-9).
+func validFrameID(id []byte) bool {
+	if len(id) == 0 {
+		return false
+	}
+	for _, c := range id {
+		if (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// Return the size of the frame headers of an ID3v2 version.
+func frameHeaderSize(ver byte) uint {
+	if ver < 3 {
+		return 6
+	}
+	return 10
+}
+
+// Tell if b is only made of padding (null bytes).
+func isPadding(b []byte) bool {
+	for _, c := range b {
+		if c != 0x00 {
+			return false
+		}
+	}
+	return true
+}
+
+// Return the size of the frame header at the beginning of b, as a plain
+// 32-bit integer.
+func plainSize(b []byte) uint {
+	return uint(b[4])<<24 | uint(b[5])<<16 | uint(b[6])<<8 | uint(b[7])
+}
+
+// Tell if a frame, or the padding, or the end of the tag, may start at offset
+// i of hb.
+func nextFrameAt(hb []byte, i uint) bool {
+	switch {
+	case i > uint(len(hb)):
+		return false
+	case i+10 > uint(len(hb)) || hb[i] == 0x00:
+		return isPadding(hb[i:])
+	default:
+		return validFrameID(hb[i : i+4])
+	}
+}
+
+// Return the size of the ID3v2.4 frame whose header starts at offset i of
+// hb, and tell if it has been written as a plain integer rather than as a
+// syncsafe one. Both interpretations are tried, the syncsafe one first, and
+// the one that leads to a valid next frame wins.
+func frameSize24(hb []byte, i uint) (uint, bool) {
+	b := hb[i : i+10]
+	ss, plain := syncsafe(b[4:8]), plainSize(b)
+	if !isSyncsafe(b[4:8]) {
+		return plain, true
+	}
+	if ss == plain || nextFrameAt(hb, i+10+ss) || !nextFrameAt(hb, i+10+plain) {
+		return ss, false
+	}
+	return plain, true
+}
+
+// Tell if a plausible frame header, of a known frame whose size fits the
+// tag, starts at offset i of hb.
+func plausibleFrame(ver byte, hb []byte, i uint) bool {
+	fhs := frameHeaderSize(ver)
+	if i+fhs > uint(len(hb)) {
+		return false
+	}
+	if ver < 3 { // 3-character IDs and 3-byte sizes
+		_, ok := v22IDs[string(hb[i:i+3])]
+		size := uint(hb[i+3])<<16 | uint(hb[i+4])<<8 | uint(hb[i+5])
+		return ok && size != 0 && size <= uint(len(hb))-i-fhs
+	}
+	if !validFrameID(hb[i : i+4]) {
+		return false
+	}
+	if _, ok := tagmap[string(hb[i:i+4])]; !ok {
+		return false
+	}
+	size := plainSize(hb[i:])
+	if ver >= 4 {
+		size, _ = frameSize24(hb, i)
+	}
+	return size != 0 && size <= uint(len(hb))-i-10
+}
+
+// Return the offset of the first plausible frame header of hb at or after
+// offset from, or the size of hb if there is none.
+func resync(ver byte, hb []byte, from uint) uint {
+	for i := from; i+frameHeaderSize(ver) <= uint(len(hb)); i++ {
+		if plausibleFrame(ver, hb, i) {
+			return i
+		}
+	}
+	return uint(len(hb))
+}
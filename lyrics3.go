@@ -0,0 +1,111 @@
+package id3v2
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+const (
+	lyrics3Begin = "LYRICSBEGIN"
+	lyrics3End   = "LYRICS200"
+)
+
+// Lyrics3Field is a field of a Lyrics3v2 tag.
+type Lyrics3Field struct {
+	ID    string // 3-char field ID (eg, "LYR" for the lyrics)
+	Value string
+}
+
+// Lyrics3 is a Lyrics3v2 tag, found before the ID3v1 tag of a file.
+type Lyrics3 struct {
+	Offset int64 // file offset of the tag
+	Size   int64 // size of the tag, "LYRICSBEGIN" to "LYRICS200" included
+	Fields []Lyrics3Field
+}
+
+// Names of the Lyrics3v2 fields.
+var lyrics3Names = map[string]string{
+	"IND": "Indications",
+	"LYR": "Lyrics",
+	"INF": "Additional information",
+	"AUT": "Lyrics author",
+	"EAL": "Extended album",
+	"EAR": "Extended artist",
+	"ETT": "Extended title",
+	"IMG": "Image links",
+}
+
+// Read the Lyrics3v2 tag that ends at offset end, if any.
+//
+//	"LYRICSBEGIN"
+//
+// Then for every field:
+//
+//	Field ID      3 bytes
+//	Field size    5 digits
+//	Field data    <field size bytes>
+//
+// And finally:
+//
+//	Tag size      6 digits ("LYRICSBEGIN" and fields)
+//	"LYRICS200"
+func readLyrics3(sf io.ReaderAt, end int64) (*Lyrics3, error) {
+	e := make([]byte, 6+len(lyrics3End))
+	if end < int64(len(e)+len(lyrics3Begin)) {
+		return nil, nil
+	}
+	_, err := sf.ReadAt(e, end-int64(len(e)))
+	if err != nil {
+		return nil, err
+	}
+	if string(e[6:]) != lyrics3End {
+		return nil, nil
+	}
+	size, err := strconv.ParseUint(string(e[:6]), 10, 32)
+	if err != nil || int64(size) < int64(len(lyrics3Begin)) || int64(size) > end-int64(len(e)) {
+		return nil, errors.New(fmt.Sprintf("Invalid Lyrics3v2 tag size %q", e[:6]))
+	}
+	l3 := &Lyrics3{Offset: end - int64(len(e)) - int64(size), Size: int64(size) + int64(len(e))}
+	b := make([]byte, size)
+	_, err = sf.ReadAt(b, l3.Offset)
+	if err != nil {
+		return nil, err
+	}
+	if string(b[:len(lyrics3Begin)]) != lyrics3Begin {
+		return nil, errors.New(fmt.Sprintf("Missing %s at 0x%x", lyrics3Begin, l3.Offset))
+	}
+	if Verbose >= 2 {
+		fmt.Printf("Lyrics3v2 tag at 0x%x, 0x%x bytes\n", l3.Offset, l3.Size)
+	}
+	for b = b[len(lyrics3Begin):]; len(b) > 0; {
+		if len(b) < 8 {
+			return nil, errors.New("Truncated Lyrics3v2 field")
+		}
+		n, err := strconv.ParseUint(string(b[3:8]), 10, 32)
+		if err != nil || n > uint64(len(b)-8) {
+			return nil, errors.New(fmt.Sprintf("Invalid Lyrics3v2 field size %q", b[3:8]))
+		}
+		l3.Fields = append(l3.Fields, Lyrics3Field{ID: string(b[:3]), Value: decodeISO8859(b[8 : 8+n])})
+		b = b[8+n:]
+	}
+	return l3, nil
+}
+
+// Add the fields of a Lyrics3v2 tag to the processed tags. Their keys in
+// mi.AllTags are prefixed with "LYRICS3:".
+func (mi *MP3Info) addLyrics3(l3 *Lyrics3) {
+	for _, f := range l3.Fields {
+		name, ok := lyrics3Names[f.ID]
+		if !ok {
+			name = f.ID
+		}
+		pt := &ProcessedTag{Name: name, Value: f.Value, ID: f.ID, Origin: OriginLyrics3}
+		if Verbose >= 1 {
+			fmt.Printf("%s (Lyrics3): %s\n", name, f.Value)
+		}
+		mi.AllTags["LYRICS3:"+f.ID] = pt
+		mi.Frames = append(mi.Frames, pt)
+	}
+}
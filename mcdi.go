@@ -0,0 +1,172 @@
+package id3v2
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	cdLeadIn     = 150   // frames of the 2-second lead-in before LBA 0
+	cdLeadOut    = 0xaa  // track number of the lead-out in a binary TOC
+	cdDataTrack  = 0x04  // control bit set for data tracks
+	cdSessionGap = 11400 // frames between the sessions of an enhanced CD
+)
+
+// CDTrack is a track of a CD table of contents.
+type CDTrack struct {
+	Number  uint8  // track number
+	Control uint8  // control nibble (0x04 set for data tracks)
+	Offset  uint32 // start of the track, in frames (1/75 s), lead-in included
+}
+
+// Data tells if the track is a data track.
+func (t *CDTrack) Data() bool {
+	return t.Control&cdDataTrack != 0
+}
+
+// CDTOC is the table of contents of a music CD, as found in an MCDI (Music
+// CD identifier) frame.
+type CDTOC struct {
+	FirstTrack uint8
+	LastTrack  uint8
+	Tracks     []CDTrack
+	LeadOut    uint32 // start of the lead-out, in frames, lead-in included
+}
+
+// Decode the payload of an MCDI frame.
+// Most rippers store the binary TOC returned by the READ TOC command of the
+// drive (4-byte header, then 8 bytes per track, lead-out included). Windows
+// Media Player stores a UTF-16 string of '+'-separated hexadecimal numbers
+// (track count, track offsets and lead-out offset) instead.
+func parseMCDI(pl []byte) (*CDTOC, error) {
+	// The length of a binary TOC (8*n+2) never has a null low byte.
+	if len(pl) >= 2 && (pl[1] == 0x00 || pl[0] == 0xff && pl[1] == 0xfe) {
+		return parseTextTOC(pl)
+	}
+	if len(pl) < 4 {
+		return nil, errors.New(fmt.Sprintf("MCDI frame too short (%d bytes)", len(pl)))
+	}
+	return parseBinaryTOC(pl)
+}
+
+// Decode a binary TOC.
+//
+//	TOC data length  $xx xx (not including these two bytes)
+//	First track      $xx
+//	Last track       $xx
+//
+// Then for every track, lead-out (track 0xAA) included:
+//
+//	Reserved         $xx
+//	ADR/Control      $xx
+//	Track number     $xx
+//	Reserved         $xx
+//	Start address    $xx xx xx xx (LBA)
+func parseBinaryTOC(pl []byte) (*CDTOC, error) {
+	l := int(pl[0])<<8 | int(pl[1])
+	if l+2 < len(pl) {
+		pl = pl[:l+2]
+	}
+	if (len(pl)-4)%8 != 0 {
+		return nil, errors.New(fmt.Sprintf("Invalid CD TOC length (%d bytes)", len(pl)))
+	}
+	toc := &CDTOC{FirstTrack: pl[2], LastTrack: pl[3]}
+	leadout := false
+	for i := 4; i < len(pl); i += 8 {
+		d := pl[i : i+8]
+		lba := uint32(d[4])<<24 | uint32(d[5])<<16 | uint32(d[6])<<8 | uint32(d[7])
+		if d[2] == cdLeadOut {
+			toc.LeadOut = lba + cdLeadIn
+			leadout = true
+			break
+		}
+		toc.Tracks = append(toc.Tracks, CDTrack{Number: d[2], Control: d[1] & 0x0f, Offset: lba + cdLeadIn})
+	}
+	if !leadout || len(toc.Tracks) == 0 {
+		return nil, errors.New("Incomplete CD TOC")
+	}
+	return toc, nil
+}
+
+// Decode a Windows Media Player TOC.
+func parseTextTOC(pl []byte) (*CDTOC, error) {
+	s, err := decodeUTF16BOM(pl, binary.LittleEndian)
+	if err != nil {
+		return nil, err
+	}
+	f := strings.Split(strings.Trim(s, "+\x00"), "+")
+	var v []uint32
+	for _, x := range f {
+		n, err := strconv.ParseUint(x, 16, 32)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Invalid CD TOC string %q", s))
+		}
+		v = append(v, uint32(n))
+	}
+	if len(v) < 3 || int(v[0]) != len(v)-2 || v[0] > 99 {
+		return nil, errors.New(fmt.Sprintf("Invalid CD TOC string %q", s))
+	}
+	toc := &CDTOC{FirstTrack: 1, LastTrack: uint8(v[0]), LeadOut: v[len(v)-1]}
+	for i, o := range v[1 : len(v)-1] {
+		toc.Tracks = append(toc.Tracks, CDTrack{Number: uint8(i + 1), Offset: o})
+	}
+	return toc, nil
+}
+
+// Sum the decimal digits of n.
+func cddbSum(n uint32) uint32 {
+	var s uint32
+	for ; n > 0; n /= 10 {
+		s += n % 10
+	}
+	return s
+}
+
+// CDDBID returns the CDDB/freedb disc ID of the CD, as an 8-digit
+// hexadecimal string, or "" if the TOC has no track.
+func (toc *CDTOC) CDDBID() string {
+	if len(toc.Tracks) == 0 {
+		return ""
+	}
+	var n uint32
+	for _, t := range toc.Tracks {
+		n += cddbSum(t.Offset / 75)
+	}
+	t := toc.LeadOut/75 - toc.Tracks[0].Offset/75
+	return fmt.Sprintf("%08x", (n%0xff)<<24|t<<8|uint32(len(toc.Tracks)))
+}
+
+// MusicBrainzID returns the MusicBrainz disc ID of the CD, or "" if the TOC
+// has no track.
+// Only the audio session counts: when the last track of the CD is a data
+// track (enhanced CD), the lead-out is moved to the end of the audio session.
+func (toc *CDTOC) MusicBrainzID() string {
+	if len(toc.Tracks) == 0 {
+		return ""
+	}
+	tracks := toc.Tracks
+	leadout := toc.LeadOut
+	if l := len(tracks); l > 1 && tracks[l-1].Data() {
+		leadout = tracks[l-1].Offset - cdSessionGap
+		tracks = tracks[:l-1]
+	}
+	var offsets [100]uint32 // offsets[0] is the lead-out
+	offsets[0] = leadout
+	for _, t := range tracks {
+		if t.Number >= 1 && t.Number <= 99 {
+			offsets[t.Number] = t.Offset
+		}
+	}
+	h := sha1.New()
+	fmt.Fprintf(h, "%02X%02X", tracks[0].Number, tracks[len(tracks)-1].Number)
+	for _, o := range offsets {
+		fmt.Fprintf(h, "%08X", o)
+	}
+	id := base64.StdEncoding.EncodeToString(h.Sum(nil))
+	return strings.NewReplacer("+", ".", "/", "_", "=", "-").Replace(id)
+}
@@ -0,0 +1,78 @@
+package id3v2
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// The TOC of the example of the MusicBrainz disc ID documentation.
+var (
+	tocOffsets = []uint32{150, 15363, 32314, 46592, 63414, 80489}
+	tocLeadOut = uint32(95462)
+)
+
+// Return the TOC as a binary TOC (READ TOC command).
+func binaryTOC() []byte {
+	n := len(tocOffsets) + 1
+	b := []byte{byte((8*n + 2) >> 8), byte(8*n + 2), 1, byte(len(tocOffsets))}
+	for i, o := range append(tocOffsets, tocLeadOut) {
+		nb := byte(i + 1)
+		if i == len(tocOffsets) {
+			nb = cdLeadOut
+		}
+		lba := o - cdLeadIn
+		b = append(b, 0, 0x10, nb, 0, byte(lba>>24), byte(lba>>16), byte(lba>>8), byte(lba))
+	}
+	return b
+}
+
+// Return the TOC as a Windows Media Player TOC.
+func textTOC() []byte {
+	f := []string{fmt.Sprintf("%X", len(tocOffsets))}
+	for _, o := range append(tocOffsets, tocLeadOut) {
+		f = append(f, fmt.Sprintf("%X", o))
+	}
+	return encodeString(0x01, strings.Join(f, "+")+"\x00")
+}
+
+func TestMCDIDiscIDs(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		pl   []byte
+	}{
+		{"binary", binaryTOC()},
+		{"text", textTOC()},
+	} {
+		mi := readFrames(t, 3, rawFrame("MCDI", tc.pl))
+		toc, ok := mi.AllTags["MCDI"].Data.(*CDTOC)
+		if !ok {
+			t.Fatalf("%s: MCDI frame %+v", tc.name, mi.AllTags["MCDI"])
+		}
+		if len(toc.Tracks) != 6 || toc.FirstTrack != 1 || toc.LastTrack != 6 || toc.LeadOut != tocLeadOut || toc.Tracks[1].Offset != 15363 {
+			t.Errorf("%s: TOC %+v", tc.name, toc)
+		}
+		if id := toc.MusicBrainzID(); id != "49HHV7Eb8UKF3aQiNmu1GR8vKTY-" {
+			t.Errorf("%s: MusicBrainz ID %s", tc.name, id)
+		}
+		if id := toc.CDDBID(); id != "3404f606" {
+			t.Errorf("%s: CDDB ID %s", tc.name, id)
+		}
+	}
+
+	// An enhanced CD: the data track is not part of the MusicBrainz ID.
+	toc := &CDTOC{FirstTrack: 1, LastTrack: 7, LeadOut: 120000}
+	for i, o := range tocOffsets {
+		toc.Tracks = append(toc.Tracks, CDTrack{Number: uint8(i + 1), Offset: o})
+	}
+	toc.Tracks = append(toc.Tracks, CDTrack{Number: 7, Control: cdDataTrack, Offset: tocLeadOut + cdSessionGap})
+	if id := toc.MusicBrainzID(); id != "49HHV7Eb8UKF3aQiNmu1GR8vKTY-" {
+		t.Errorf("enhanced CD: MusicBrainz ID %s", id)
+	}
+
+	// A TOC without tracks has no IDs.
+	empty := &CDTOC{}
+	if empty.CDDBID() != "" || empty.MusicBrainzID() != "" {
+		t.Error("IDs of an empty TOC")
+	}
+}
@@ -0,0 +1,139 @@
+package id3v2
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// MLLTRef is one reference of an MPEG location lookup table. The deviations
+// are added to the nominal distances of the table to get the actual distance
+// between this reference and the previous one.
+type MLLTRef struct {
+	BytesDev uint32 // deviation in bytes
+	MsDev    uint32 // deviation in milliseconds
+}
+
+// MLLT is the decoded content of an MLLT (MPEG location lookup table) frame.
+// It can be used to find the position of a given time in a VBR stream
+// without having to walk through all the MPEG frames.
+// The references are kept packed, as in the frame, and decoded when needed
+// (see Len and Ref), so that a table takes no more memory than its frame.
+type MLLT struct {
+	FramesBetweenRef uint16 // MPEG frames between references
+	BytesBetweenRef  uint32 // nominal bytes between references (24 bits)
+	MsBetweenRef     uint32 // nominal milliseconds between references (24 bits)
+	BitsForBytesDev  uint8  // bits used to code each bytes deviation
+	BitsForMsDev     uint8  // bits used to code each milliseconds deviation
+	refs             []byte // packed deviations of the references
+}
+
+// A bitReader reads big-endian bit fields of any width (up to 32 bits) from
+// a slice of bytes.
+type bitReader struct {
+	b   []byte
+	pos uint // position in bits
+}
+
+// Read the next n bits. The second return value is false if there is not
+// enough bits left.
+func (br *bitReader) read(n uint8) (uint32, bool) {
+	if uint(n) > uint(len(br.b))*8-br.pos {
+		return 0, false
+	}
+	var v uint32
+	for i := uint8(0); i < n; i++ {
+		bit := (br.b[br.pos/8] >> (7 - br.pos%8)) & 1
+		v = v<<1 | uint32(bit)
+		br.pos++
+	}
+	return v, true
+}
+
+// Decode the payload of an MLLT frame.
+//
+//	MPEG frames between reference  $xx xx
+//	Bytes between reference        $xx xx xx
+//	Milliseconds between reference $xx xx xx
+//	Bits for bytes deviation       $xx
+//	Bits for milliseconds dev.     $xx
+//
+// Then for every reference:
+//
+//	Deviation in bytes         %xxx....
+//	Deviation in milliseconds  %xxx....
+func parseMLLT(pl []byte) (*MLLT, error) {
+	if len(pl) < 10 {
+		return nil, errors.New(fmt.Sprintf("MLLT frame too short (%d bytes)", len(pl)))
+	}
+	m := &MLLT{
+		FramesBetweenRef: uint16(pl[0])<<8 | uint16(pl[1]),
+		BytesBetweenRef:  uint32(pl[2])<<16 | uint32(pl[3])<<8 | uint32(pl[4]),
+		MsBetweenRef:     uint32(pl[5])<<16 | uint32(pl[6])<<8 | uint32(pl[7]),
+		BitsForBytesDev:  pl[8],
+		BitsForMsDev:     pl[9],
+	}
+	if m.BitsForBytesDev > 32 || m.BitsForMsDev > 32 {
+		return nil, errors.New(fmt.Sprintf("Invalid MLLT deviation sizes (%d, %d bits)", m.BitsForBytesDev, m.BitsForMsDev))
+	}
+	if m.MsBetweenRef == 0 {
+		return nil, errors.New("Invalid MLLT frame: no milliseconds between references")
+	}
+	m.refs = pl[10:]
+	return m, nil
+}
+
+// Return the bits used by each reference.
+func (m *MLLT) bitsPerRef() uint {
+	return uint(m.BitsForBytesDev) + uint(m.BitsForMsDev)
+}
+
+// Len returns the count of references of the table.
+func (m *MLLT) Len() int {
+	bpr := m.bitsPerRef()
+	if bpr == 0 {
+		return 0
+	}
+	return int(uint(len(m.refs)) * 8 / bpr)
+}
+
+// Ref returns the reference i of the table, from 0 to Len()-1.
+func (m *MLLT) Ref(i int) MLLTRef {
+	br := bitReader{b: m.refs, pos: uint(i) * m.bitsPerRef()}
+	return m.next(&br)
+}
+
+// Decode the next reference of the table.
+func (m *MLLT) next(br *bitReader) MLLTRef {
+	bd, _ := br.read(m.BitsForBytesDev)
+	md, _ := br.read(m.BitsForMsDev)
+	return MLLTRef{BytesDev: bd, MsDev: md}
+}
+
+// Seek returns the byte offset of the given time position, relative to the
+// first MPEG frame of the audio stream.
+// The offset is interpolated between the two surrounding references of the
+// table. Beyond the last reference, it is extrapolated using the nominal
+// distances of the table.
+func (m *MLLT) Seek(t time.Duration) (int64, error) {
+	if t < 0 {
+		return 0, errors.New(fmt.Sprintf("Invalid seek position (%s)", t))
+	}
+	if m.MsBetweenRef == 0 {
+		return 0, errors.New("Invalid MLLT: no milliseconds between references")
+	}
+	ms := int64(t / time.Millisecond)
+	var pos, tpos int64 // byte and time positions of the current reference
+	br := bitReader{b: m.refs}
+	for i, n := 0, m.Len(); i < n; i++ {
+		r := m.next(&br)
+		db := int64(m.BytesBetweenRef) + int64(r.BytesDev)
+		dt := int64(m.MsBetweenRef) + int64(r.MsDev)
+		if tpos+dt > ms {
+			return pos + db*(ms-tpos)/dt, nil
+		}
+		pos += db
+		tpos += dt
+	}
+	return pos + int64(m.BytesBetweenRef)*(ms-tpos)/int64(m.MsBetweenRef), nil
+}
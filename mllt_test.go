@@ -0,0 +1,58 @@
+package id3v2
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMLLTSeek(t *testing.T) {
+	// A reference every MPEG frame, of 1000 bytes and 26 ms nominally, with
+	// 4-bit deviations: (1, 2), (3, 0) and (0, 1).
+	pl := []byte{0x00, 0x01, 0x00, 0x03, 0xe8, 0x00, 0x00, 0x1a, 4, 4, 0x12, 0x30, 0x01}
+	mi := readFrames(t, 4, rawFrame("MLLT", pl))
+	m, ok := mi.AllTags["MLLT"].Data.(*MLLT)
+	if !ok {
+		t.Fatalf("MLLT frame: %+v", mi.AllTags["MLLT"])
+	}
+	if m.FramesBetweenRef != 1 || m.BytesBetweenRef != 1000 || m.MsBetweenRef != 26 || m.Len() != 3 {
+		t.Fatalf("MLLT %+v, %d references", m, m.Len())
+	}
+	if r := m.Ref(1); r.BytesDev != 3 || r.MsDev != 0 {
+		t.Errorf("reference 1: %+v", r)
+	}
+	for _, tc := range []struct {
+		ms  int64
+		pos int64
+	}{
+		{0, 0},
+		{14, 500},         // in the first reference (1001 bytes, 28 ms)
+		{28, 1001},        // start of the second one
+		{60, 2004 + 222},  // in the third one (1000 bytes, 27 ms)
+		{100, 3004 + 730}, // beyond the table, at the nominal rate
+	} {
+		pos, err := m.Seek(time.Duration(tc.ms) * time.Millisecond)
+		if err != nil || pos != tc.pos {
+			t.Errorf("Seek(%d ms) = %d, %v, want %d", tc.ms, pos, err, tc.pos)
+		}
+	}
+	if _, err := m.Seek(-time.Second); err == nil {
+		t.Error("negative position accepted")
+	}
+}
+
+func TestMLLTLargeTable(t *testing.T) {
+	// A 1-bit deviation per reference: a large table is not rejected, nor
+	// expanded in memory.
+	pl := append([]byte{0x00, 0x01, 0x00, 0x01, 0xa1, 0x00, 0x00, 0x1a, 1, 0}, make([]byte, 1<<20)...)
+	pl[10] = 0x80
+	m, err := parseMLLT(pl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Len() != 8<<20 || m.Ref(0).BytesDev != 1 || m.Ref(1).BytesDev != 0 {
+		t.Errorf("%d references, first ones %+v %+v", m.Len(), m.Ref(0), m.Ref(1))
+	}
+	if pos, err := m.Seek(26 * time.Millisecond); err != nil || pos != 418 {
+		t.Errorf("Seek(26 ms) = %d, %v", pos, err)
+	}
+}
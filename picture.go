@@ -0,0 +1,238 @@
+package id3v2
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Picture types of APIC frames.
+const (
+	PictureOther             = iota // Other
+	PictureFileIcon                 // 32x32 pixels 'file icon' (PNG only)
+	PictureOtherFileIcon            // Other file icon
+	PictureFrontCover               // Cover (front)
+	PictureBackCover                // Cover (back)
+	PictureLeaflet                  // Leaflet page
+	PictureMedia                    // Media (e.g. label side of CD)
+	PictureLeadArtist               // Lead artist/lead performer/soloist
+	PictureArtist                   // Artist/performer
+	PictureConductor                // Conductor
+	PictureBand                     // Band/Orchestra
+	PictureComposer                 // Composer
+	PictureLyricist                 // Lyricist/text writer
+	PictureRecordingLocation        // Recording Location
+	PictureDuringRecording          // During recording
+	PictureDuringPerformance        // During performance
+	PictureScreenCapture            // Movie/video screen capture
+	PictureBrightFish               // A bright coloured fish
+	PictureIllustration             // Illustration
+	PictureArtistLogo               // Band/artist logotype
+	PicturePublisherLogo            // Publisher/Studio logotype
+)
+
+// Picture is the decoded content of an APIC (attached picture) frame.
+type Picture struct {
+	MIMEType    string // eg, "image/jpeg", or "-->" if Data is a URL
+	Type        byte   // see the Picture* constants
+	Description string
+	Data        []byte // the image file
+}
+
+// NewPicture returns a picture of a given type whose MIME type is detected
+// from the magic bytes of the image: JPEG, PNG, GIF or WebP.
+func NewPicture(data []byte, ptype byte, desc string) (*Picture, error) {
+	mt := imageType(data)
+	if mt == "" {
+		return nil, errors.New("Unknown image format")
+	}
+	return &Picture{MIMEType: mt, Type: ptype, Description: desc, Data: data}, nil
+}
+
+// ReadPicture returns a picture of a given type from an image file (see
+// NewPicture).
+func ReadPicture(path string, ptype byte, desc string) (*Picture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p, err := NewPicture(data, ptype, desc)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("%s: %s", path, err))
+	}
+	return p, nil
+}
+
+// Return the MIME type of an image from its magic bytes, or "" if the
+// format is unknown.
+func imageType(b []byte) string {
+	switch {
+	case bytes.HasPrefix(b, []byte{0xff, 0xd8, 0xff}):
+		return "image/jpeg"
+	case bytes.HasPrefix(b, []byte("\x89PNG\r\n\x1a\n")):
+		return "image/png"
+	case bytes.HasPrefix(b, []byte("GIF87a")), bytes.HasPrefix(b, []byte("GIF89a")):
+		return "image/gif"
+	case len(b) >= 12 && string(b[:4]) == "RIFF" && string(b[8:12]) == "WEBP":
+		return "image/webp"
+	}
+	return ""
+}
+
+// Dimensions returns the width and height of the picture, read from the
+// header of the image: JPEG, PNG, GIF or WebP.
+func (p *Picture) Dimensions() (width, height int, err error) {
+	b := p.Data
+	switch imageType(b) {
+	case "image/jpeg":
+		return jpegDimensions(b)
+	case "image/png":
+		// Signature (8 bytes), then the IHDR chunk: length (4 bytes), type
+		// (4 bytes), width and height (4 bytes each).
+		if len(b) >= 24 && string(b[12:16]) == "IHDR" {
+			return int(binary.BigEndian.Uint32(b[16:20])), int(binary.BigEndian.Uint32(b[20:24])), nil
+		}
+	case "image/gif":
+		// Signature (6 bytes), then the logical screen width and height
+		// (2 bytes each, little-endian).
+		if len(b) >= 10 {
+			return int(binary.LittleEndian.Uint16(b[6:8])), int(binary.LittleEndian.Uint16(b[8:10])), nil
+		}
+	case "image/webp":
+		return webpDimensions(b)
+	default:
+		return 0, 0, errors.New("Unknown image format")
+	}
+	return 0, 0, errors.New("Truncated image header")
+}
+
+// Return the dimensions of a JPEG image, from its first SOF segment.
+func jpegDimensions(b []byte) (int, int, error) {
+	for i := 2; i+4 <= len(b); {
+		if b[i] != 0xff {
+			return 0, 0, errors.New("Invalid JPEG segment")
+		}
+		m := b[i+1]
+		switch {
+		case m == 0xff: // fill byte
+			i++
+			continue
+		case m == 0xd8 || m == 0x01 || m >= 0xd0 && m <= 0xd7: // no length
+			i += 2
+			continue
+		case m == 0xd9 || m == 0xda: // end of image, start of scan
+			return 0, 0, errors.New("No JPEG frame header")
+		}
+		n := int(binary.BigEndian.Uint16(b[i+2:]))
+		if m >= 0xc0 && m <= 0xcf && m != 0xc4 && m != 0xc8 && m != 0xcc { // SOFn
+			//	Length      2 bytes
+			//	Precision   1 byte
+			//	Height      2 bytes
+			//	Width       2 bytes
+			if i+9 > len(b) {
+				break
+			}
+			return int(binary.BigEndian.Uint16(b[i+7:])), int(binary.BigEndian.Uint16(b[i+5:])), nil
+		}
+		i += 2 + n
+	}
+	return 0, 0, errors.New("Truncated image header")
+}
+
+// Return the dimensions of a WebP image, from its first chunk: VP8 (lossy),
+// VP8L (lossless) or VP8X (extended).
+func webpDimensions(b []byte) (int, int, error) {
+	if len(b) < 30 {
+		return 0, 0, errors.New("Truncated image header")
+	}
+	c := b[20:] // chunk data
+	switch string(b[12:16]) {
+	case "VP8 ": // frame tag (3 bytes), start code (3 bytes), 14-bit sizes
+		if c[3] != 0x9d || c[4] != 0x01 || c[5] != 0x2a {
+			break
+		}
+		return int(binary.LittleEndian.Uint16(c[6:]) & 0x3fff), int(binary.LittleEndian.Uint16(c[8:]) & 0x3fff), nil
+	case "VP8L": // signature (1 byte), then 14-bit sizes minus one
+		if c[0] != 0x2f {
+			break
+		}
+		v := binary.LittleEndian.Uint32(c[1:])
+		return int(v&0x3fff) + 1, int(v>>14&0x3fff) + 1, nil
+	case "VP8X": // flags (4 bytes), then 24-bit sizes minus one
+		w := int(c[4]) | int(c[5])<<8 | int(c[6])<<16
+		h := int(c[7]) | int(c[8])<<8 | int(c[9])<<16
+		return w + 1, h + 1, nil
+	}
+	return 0, 0, errors.New("Invalid WebP header")
+}
+
+// Frame returns the picture as an APIC frame, to be added to a tag. Its
+// description is written in ISO-8859-1 when possible, in UTF-16 otherwise.
+func (p *Picture) Frame() *ProcessedTag {
+	//	Text encoding   $xx
+	//	MIME type       <text string> $00
+	//	Picture type    $xx
+	//	Description     <text string according to encoding> $00 (00)
+	//	Picture data    <binary data>
+	et := textEncoding(3, p.Description)
+	pl := append([]byte{et}, p.MIMEType...)
+	pl = append(pl, 0x00, p.Type)
+	pl = append(pl, encodeString(et, p.Description)...)
+	pl = append(pl, terminator(et)...)
+	pl = append(pl, p.Data...)
+	return &ProcessedTag{Name: "Picture", Value: p.String(), Data: p, ID: "APIC", Payload: pl, Origin: OriginID3v2}
+}
+
+// String returns a short description of the picture.
+func (p *Picture) String() string {
+	return fmt.Sprintf("%s, 0x%02x, \"%s\", %d (0x%x) bytes", p.MIMEType, p.Type, p.Description, len(p.Data), len(p.Data))
+}
+
+// Pictures returns the pictures of a tag.
+func (t *Tag) Pictures() []*Picture {
+	var pics []*Picture
+	for _, pt := range t.Frames {
+		if p, ok := pt.Data.(*Picture); ok && pt.ID == "APIC" {
+			pics = append(pics, p)
+		}
+	}
+	return pics
+}
+
+// AddPicture adds a picture to a tag.
+func (t *Tag) AddPicture(p *Picture) {
+	t.alter()
+	t.Frames = append(t.Frames, p.Frame())
+}
+
+// SetPicture replaces the pictures of the type of p (eg, the front cover)
+// by p, where the first one was, or adds p if there was none.
+func (t *Tag) SetPicture(p *Picture) {
+	t.replaceFrames(MatchPicture(p.Type), p.Frame())
+}
+
+// RemovePictures removes the pictures of some types from a tag, or all of
+// them if no type is given, and returns how many pictures were removed.
+func (t *Tag) RemovePictures(types ...byte) int {
+	return t.RemoveFrames(MatchPicture(types...))
+}
+
+// MatchPicture returns a function that matches the APIC frames of some
+// picture types, or all of them if no type is given, for RemoveFrames and
+// StripFrames.
+func MatchPicture(types ...byte) func(pt *ProcessedTag) bool {
+	return func(pt *ProcessedTag) bool {
+		p, ok := pt.Data.(*Picture)
+		if !ok || pt.ID != "APIC" {
+			return false
+		}
+		for _, ptype := range types {
+			if p.Type == ptype {
+				return true
+			}
+		}
+		return len(types) == 0
+	}
+}
@@ -0,0 +1,88 @@
+package id3v2
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// Headers of 640x288 images, in all the formats supported.
+var images = map[string]string{
+	"image/jpeg": "\xff\xd8\xff\xe0\x00\x10JFIF\x00\x01\x01\x00\x00\x01\x00\x01\x00\x00" +
+		"\xff\xff\xc0\x00\x11\x08\x01\x20\x02\x80\x03\x01\x22\x00\x02\x11\x01\x03\x11\x01",
+	"image/png":  "\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR\x00\x00\x02\x80\x00\x00\x01\x20\x08\x06\x00\x00\x00",
+	"image/gif":  "GIF89a\x80\x02\x20\x01\xf7\x00\x00",
+	"VP8":        "RIFF\x00\x00\x00\x00WEBPVP8 \x00\x00\x00\x00\x00\x00\x00\x9d\x01\x2a\x80\x02\x20\x01\x00\x00",
+	"VP8L":       "RIFF\x00\x00\x00\x00WEBPVP8L\x00\x00\x00\x00\x2f\x7f\xc2\x47\x00\x00\x00\x00\x00\x00",
+	"image/webp": "RIFF\x00\x00\x00\x00WEBPVP8X\x0a\x00\x00\x00\x00\x00\x00\x00\x7f\x02\x00\x1f\x01\x00",
+}
+
+func TestPictureDimensions(t *testing.T) {
+	for name, img := range images {
+		p, err := NewPicture([]byte(img), PictureFrontCover, "")
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if mt := p.MIMEType; mt != name && mt != "image/webp" {
+			t.Errorf("%s: MIME type %s", name, mt)
+		}
+		if w, h, err := p.Dimensions(); w != 640 || h != 288 || err != nil {
+			t.Errorf("%s: %dx%d (%v)", name, w, h, err)
+		}
+	}
+	for name, img := range map[string]string{
+		"unknown":          "BM\x00\x00",
+		"truncated PNG":    images["image/png"][:20],
+		"truncated GIF":    "GIF87a\x80\x02",
+		"JPEG without SOF": "\xff\xd8\xff\xda\x00\x02",
+		"JPEG segment":     "\xff\xd8\xff\xe0\x00\x04\x00\x00\x00\x00\x00",
+		"WebP chunk":       "RIFF\x00\x00\x00\x00WEBPVP8 \x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x80\x02\x20\x01\x00\x00",
+	} {
+		p := &Picture{Data: []byte(img)}
+		if w, h, err := p.Dimensions(); err == nil {
+			t.Errorf("%s: %dx%d", name, w, h)
+		}
+	}
+	if _, err := NewPicture([]byte("BM\x00\x00"), PictureFrontCover, ""); err == nil {
+		t.Error("unknown image format accepted")
+	}
+}
+
+func TestPictureFrames(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cover.png")
+	if err := os.WriteFile(path, []byte(images["image/png"]), 0644); err != nil {
+		t.Fatal(err)
+	}
+	front, err := ReadPicture(path, PictureFrontCover, "Cövér ✓")
+	if err != nil {
+		t.Fatal(err)
+	}
+	back, err := NewPicture([]byte(images["image/jpeg"]), PictureBackCover, "Back")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newFront := &Picture{MIMEType: "image/gif", Type: PictureFrontCover, Data: []byte(images["image/gif"])}
+	for _, ver := range []byte{3, 4} {
+		tag := &Tag{Version: ver}
+		tag.AddPicture(front)
+		tag.AddPicture(back)
+		tag = reload(t, tag)
+		if pics := tag.Pictures(); !reflect.DeepEqual(pics, []*Picture{front, back}) {
+			t.Errorf("ID3v2.%d: pictures %v", ver, pics)
+		}
+
+		// The new front cover takes the place of the first one.
+		tag.SetPicture(newFront)
+		tag = reload(t, tag)
+		if pics := tag.Pictures(); !reflect.DeepEqual(pics, []*Picture{newFront, back}) {
+			t.Errorf("ID3v2.%d: pictures %v", ver, pics)
+		}
+		if n := tag.RemovePictures(PictureBackCover, PictureLeaflet); n != 1 {
+			t.Errorf("ID3v2.%d: %d pictures removed", ver, n)
+		}
+		if n := tag.RemovePictures(); n != 1 || len(tag.Pictures()) != 0 {
+			t.Errorf("ID3v2.%d: %d pictures removed, %d left", ver, n, len(tag.Pictures()))
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package id3v2
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Popularimeter is the decoded content of a POPM (popularimeter) frame: the
+// rating of the file by a user, and how many times the user played it.
+type Popularimeter struct {
+	Email   string // identifies the user
+	Rating  byte   // 1 (worst) to 255 (best), 0 if unknown
+	Counter uint64 // play counter, or 0 if omitted
+}
+
+// String returns a short description of the rating.
+func (p *Popularimeter) String() string {
+	return fmt.Sprintf("%q: %d/255, played %d times", p.Email, p.Rating, p.Counter)
+}
+
+// Decode the payload of a POPM frame.
+//
+//	Email to user   <text string> $00
+//	Rating          $xx
+//	Counter         $xx xx xx xx (xx ...), optional
+func parsePOPM(pl []byte) (*Popularimeter, error) {
+	email, rest, ok := splitString(0x00, pl)
+	if !ok || len(rest) < 1 {
+		return nil, errors.New("Truncated POPM frame")
+	}
+	p := &Popularimeter{Email: decodeISO8859(email), Rating: rest[0]}
+	if len(rest) > 9 {
+		return nil, errors.New("POPM counter too large")
+	}
+	for _, c := range rest[1:] {
+		p.Counter = p.Counter<<8 | uint64(c)
+	}
+	return p, nil
+}
+
+// Encode a POPM frame payload. The email is an ISO-8859-1 string. The
+// counter is omitted if it is 0, and takes at least 4 bytes otherwise.
+func (p *Popularimeter) payload() []byte {
+	pl := append(encodeString(0x00, p.Email), 0x00, p.Rating)
+	if p.Counter == 0 {
+		return pl
+	}
+	n := 4
+	for n < 8 && p.Counter>>(8*uint(n)) != 0 {
+		n++
+	}
+	for i := n - 1; i >= 0; i-- {
+		pl = append(pl, byte(p.Counter>>(8*uint(i))))
+	}
+	return pl
+}
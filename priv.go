@@ -0,0 +1,133 @@
+package id3v2
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// PrivateFrame is the decoded content of a PRIV (private) frame.
+// Value is set when a decoder is registered for the owner of the frame
+// (see RegisterPrivateDecoder), and Data always holds the raw private data.
+type PrivateFrame struct {
+	Owner string      // owner identifier
+	Data  []byte      // private data
+	Value interface{} // decoded private data, or nil
+}
+
+// A PrivateDecoder decodes the private data of the PRIV frames of a given
+// owner into a typed value.
+type PrivateDecoder func(data []byte) (interface{}, error)
+
+// GUID is a Microsoft GUID, as stored by Windows Media Player.
+type GUID [16]byte
+
+// String returns the GUID in its usual form. The first three groups are
+// stored in little-endian order.
+func (g GUID) String() string {
+	return fmt.Sprintf("{%02X%02X%02X%02X-%02X%02X-%02X%02X-%02X%02X-%02X%02X%02X%02X%02X%02X}",
+		g[3], g[2], g[1], g[0], g[5], g[4], g[7], g[6],
+		g[8], g[9], g[10], g[11], g[12], g[13], g[14], g[15])
+}
+
+// TransportStreamTimestamp is the 33-bit MPEG-2 presentation time stamp
+// (90 kHz clock) of the first sample of an Apple HLS audio segment.
+type TransportStreamTimestamp uint64
+
+// Duration returns the time stamp as a duration.
+func (ts TransportStreamTimestamp) Duration() time.Duration {
+	return time.Duration(ts) * time.Second / 90000
+}
+
+// String returns the time stamp as a duration.
+func (ts TransportStreamTimestamp) String() string {
+	return ts.Duration().String()
+}
+
+var (
+	// PRIV frames decoders, by owner identifier, and their lock (decoders
+	// may be registered while files are processed)
+	privmu  sync.RWMutex
+	privmap = map[string]PrivateDecoder{
+		"WM/MediaClassPrimaryID":                       privGUID, // Windows Media Player
+		"WM/MediaClassSecondaryID":                     privGUID,
+		"WM/WMContentID":                               privGUID,
+		"WM/WMCollectionID":                            privGUID,
+		"WM/WMCollectionGroupID":                       privGUID,
+		"WM/UniqueFileIdentifier":                      privUTF16, // AMGa_id=R 123;AMGp_id=...
+		"WM/Provider":                                  privUTF16,
+		"WM/Publisher":                                 privUTF16,
+		"WM/ProviderRating":                            privUTF16,
+		"WM/ProviderStyle":                             privUTF16,
+		"www.amazon.com":                               privText, // Amazon MP3 store
+		"Google/StoreId":                               privText, // Google Play Music
+		"Google/StoreLabelCode":                        privText,
+		"com.apple.streaming.transportStreamTimestamp": privPTS, // Apple HLS
+	}
+)
+
+// RegisterPrivateDecoder registers the decoder of the PRIV frames of a given
+// owner, replacing the existing one, if any. A nil decoder unregisters it.
+// It is safe to call while files are processed, which only use it for the
+// frames processed afterwards.
+func RegisterPrivateDecoder(owner string, dec PrivateDecoder) {
+	privmu.Lock()
+	defer privmu.Unlock()
+	if dec == nil {
+		delete(privmap, owner)
+		return
+	}
+	privmap[owner] = dec
+}
+
+// Return the decoder of the PRIV frames of an owner, if any.
+func privateDecoder(owner string) (PrivateDecoder, bool) {
+	privmu.RLock()
+	defer privmu.RUnlock()
+	dec, ok := privmap[owner]
+	return dec, ok
+}
+
+// Decode a little-endian GUID.
+func privGUID(data []byte) (interface{}, error) {
+	if len(data) != 16 {
+		return nil, errors.New(fmt.Sprintf("Invalid GUID length (%d bytes)", len(data)))
+	}
+	var g GUID
+	copy(g[:], data)
+	return g, nil
+}
+
+// Decode a null-terminated UTF-16LE string.
+func privUTF16(data []byte) (interface{}, error) {
+	if len(data)%2 != 0 {
+		return nil, errors.New(fmt.Sprintf("Invalid UTF-16 length (%d bytes)", len(data)))
+	}
+	s, _, _ := splitString(0x01, data)
+	return decodeUTF16BOM(s, binary.LittleEndian)
+}
+
+// Decode a null-terminated string, which may be UTF-16LE (with or without a
+// BOM) or ISO 8859-1.
+func privText(data []byte) (interface{}, error) {
+	if len(data) >= 2 && (data[0] == 0xff && data[1] == 0xfe || data[0] != 0x00 && data[1] == 0x00) {
+		return privUTF16(data)
+	}
+	s, _, _ := splitString(0x00, data)
+	return decodeISO8859(s), nil
+}
+
+// Decode an Apple HLS time stamp: a 33-bit PTS stored in a big-endian
+// 8-byte integer.
+func privPTS(data []byte) (interface{}, error) {
+	if len(data) != 8 {
+		return nil, errors.New(fmt.Sprintf("Invalid time stamp length (%d bytes)", len(data)))
+	}
+	var v uint64
+	for _, b := range data {
+		v = v<<8 | uint64(b)
+	}
+	return TransportStreamTimestamp(v & (1<<33 - 1)), nil
+}
@@ -0,0 +1,56 @@
+package id3v2
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPrivateFrames(t *testing.T) {
+	guid := []byte{0x33, 0x22, 0x11, 0x00, 0x55, 0x44, 0x77, 0x66, 0x88, 0x99, 0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff}
+	for _, tc := range []struct {
+		owner string
+		data  []byte
+		want  interface{} // decoded value, or nil
+		value string      // value of the frame
+	}{
+		{"WM/MediaClassPrimaryID", guid, GUID(*(*[16]byte)(guid)), "{00112233-4455-6677-8899-AABBCCDDEEFF}"},
+		{"WM/Provider", []byte("A\x00M\x00G\x00\x00\x00"), "AMG", "AMG"},
+		{"www.amazon.com", []byte("\xff\xfeI\x00D\x00\x00\x00"), "ID", "ID"},
+		{"Google/StoreId", []byte("Tx1234\x00"), "Tx1234", "Tx1234"},
+		{"com.apple.streaming.transportStreamTimestamp", []byte{0xfe, 0, 0, 0, 0, 0x01, 0x5f, 0x90}, TransportStreamTimestamp(90000), "1s"},
+		{"WM/WMContentID", []byte{1, 2, 3}, nil, "3 bytes (Invalid GUID length (3 bytes))"},
+		{"unknown.example", []byte{1, 2, 3, 4}, nil, "4 bytes"},
+	} {
+		pl := append([]byte(tc.owner+"\x00"), tc.data...)
+		mi := readFrames(t, 3, rawFrame("PRIV", pl))
+		pt := mi.AllTags["PRIV"]
+		p, ok := pt.Data.(*PrivateFrame)
+		if !ok || p.Owner != tc.owner || string(p.Data) != string(tc.data) || p.Value != tc.want || pt.Name != tc.owner || pt.Value != tc.value {
+			t.Errorf("%s: %+v, data %+v", tc.owner, pt, pt.Data)
+		}
+	}
+	if d := TransportStreamTimestamp(45000).Duration(); d != 500*time.Millisecond {
+		t.Errorf("duration %s", d)
+	}
+}
+
+func TestRegisterPrivateDecoder(t *testing.T) {
+	const owner = "test.example"
+	RegisterPrivateDecoder(owner, func(data []byte) (interface{}, error) {
+		if len(data) == 0 {
+			return nil, errors.New("No data")
+		}
+		return int(data[0]), nil
+	})
+	pl := []byte(owner + "\x00\x2a")
+	mi := readFrames(t, 4, rawFrame("PRIV", pl))
+	if p := mi.AllTags["PRIV"].Data.(*PrivateFrame); p.Value != 42 {
+		t.Errorf("registered decoder: %+v", p)
+	}
+	RegisterPrivateDecoder(owner, nil)
+	mi = readFrames(t, 4, rawFrame("PRIV", pl))
+	if p := mi.AllTags["PRIV"].Data.(*PrivateFrame); p.Value != nil {
+		t.Errorf("unregistered decoder: %+v", p)
+	}
+}
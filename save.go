@@ -0,0 +1,104 @@
+package id3v2
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// SaveTags writes a tag at the beginning of an MP3 file, in place of its
+// current ID3v2 tags, if any (junk before them, and tags at the end of the
+// file, are left untouched).
+// When the new tag fits in the space of the current tags, padding included,
+// it is written over them, and its padding is extended to fill this space:
+// the audio data is not copied. Otherwise, the file is rewritten to a
+// temporary file that then replaces it, with the mode of the original file.
+// In both cases, the modification time of the file is preserved.
+func SaveTags(path string, tag *Tag) error {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	mi, err := ReadAllTags(f, fi.Size())
+	if err != nil {
+		return err
+	}
+
+	// The current tags lie between start and the audio data.
+	start := mi.AudioStart
+	if len(mi.Layouts) > 0 && mi.Layouts[0].Offset < mi.AudioStart {
+		start = mi.Layouts[0].Offset
+	}
+	b, err := tag.Encode()
+	if err != nil {
+		return err
+	}
+	if avail := mi.AudioStart - start; int64(len(b)) <= avail && (!tag.Footer || int64(len(b)) == avail) {
+		nt := *tag
+		nt.Padding += int(avail - int64(len(b)))
+		if b, err = nt.Encode(); err != nil {
+			return err
+		}
+		if _, err = f.WriteAt(b, start); err != nil {
+			return err
+		}
+		if err = f.Close(); err != nil {
+			return err
+		}
+		return os.Chtimes(path, fi.ModTime(), fi.ModTime())
+	}
+	return rewrite(path, f, fi, func(w io.Writer) error {
+		// Copy the junk before the tag, the tag and the rest of the file.
+		if _, err := io.Copy(w, io.NewSectionReader(f, 0, start)); err != nil {
+			return err
+		}
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+		_, err := io.Copy(w, io.NewSectionReader(f, mi.AudioStart, fi.Size()-mi.AudioStart))
+		return err
+	})
+}
+
+// Rewrite a file f, whose information is fi, through a temporary file
+// renamed to the original one. The content of the new file is written by
+// the copy function.
+func rewrite(path string, f *os.File, fi os.FileInfo, copy func(w io.Writer) error) (err error) {
+	tf, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tf.Close()
+			os.Remove(tf.Name())
+		}
+	}()
+	if err = copy(tf); err != nil {
+		return err
+	}
+	if err = tf.Sync(); err != nil {
+		return err
+	}
+
+	// Restore initial file mode for non-Windows OSes.
+	if runtime.GOOS != "windows" {
+		if err = tf.Chmod(fi.Mode()); err != nil {
+			return err
+		}
+	}
+	if err = tf.Close(); err != nil {
+		return err
+	}
+	f.Close() // before the rename, for Windows
+	if err = os.Rename(tf.Name(), path); err != nil {
+		return err
+	}
+	return os.Chtimes(path, fi.ModTime(), fi.ModTime())
+}
@@ -0,0 +1,86 @@
+package id3v2
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSaveTags(t *testing.T) {
+	audio := append(append([]byte{}, mpegFrame...), bytes.Repeat([]byte{0x55}, 1000)...)
+	v1 := v1Tag("v1 title", "", "", "", "", 0, 255)
+	oldTag, err := (&Tag{Version: 3, Padding: 200, Frames: []*ProcessedTag{newTextFrame("TIT2", "Title", "Old title")}}).Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name    string
+		junk    []byte
+		tag     []byte // current tags
+		title   string // new title
+		inPlace bool
+	}{
+		{"in place", nil, oldTag, "New title", true},
+		{"in place after junk", []byte("junk"), oldTag, "New title", true},
+		{"rewrite", nil, oldTag, strings.Repeat("Long title ", 50), false},
+		{"rewrite after junk", []byte("junk"), oldTag, strings.Repeat("Long title ", 50), false},
+		{"no tag", nil, nil, "New title", false},
+	} {
+		path := filepath.Join(t.TempDir(), "test.mp3")
+		file := bytes.Join([][]byte{tc.junk, tc.tag, audio, v1}, nil)
+		if err := os.WriteFile(path, file, 0640); err != nil {
+			t.Fatal(err)
+		}
+		mtime := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+		fi, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tag := &Tag{Version: 4, Frames: []*ProcessedTag{newTextFrame("TIT2", "Title", tc.title)}}
+		if err := SaveTags(path, tag); err != nil {
+			t.Fatalf("%s: %s", tc.name, err)
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		nfi, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if os.SameFile(fi, nfi) != tc.inPlace || (len(b) == len(file)) != tc.inPlace {
+			t.Errorf("%s: same file %v, size %d -> %d", tc.name, os.SameFile(fi, nfi), len(file), len(b))
+		}
+		if !nfi.ModTime().Equal(mtime) || nfi.Mode() != fi.Mode() {
+			t.Errorf("%s: modified on %s, mode %s", tc.name, nfi.ModTime(), nfi.Mode())
+		}
+
+		// The junk, audio data and ID3v1 tag are untouched.
+		if !bytes.HasPrefix(b, tc.junk) || !bytes.HasSuffix(b, append(append([]byte{}, audio...), v1...)) {
+			t.Errorf("%s: junk or audio data altered", tc.name)
+		}
+		mi, err := ReadAllTags(bytes.NewReader(b), int64(len(b)))
+		if err != nil {
+			t.Fatalf("%s: %s", tc.name, err)
+		}
+		if len(mi.Layouts) != 1 || mi.Layouts[0].Version != 4 || mi.Layouts[0].Offset != int64(len(tc.junk)) {
+			t.Fatalf("%s: tags %+v", tc.name, mi.Layouts)
+		}
+		if pt := mi.AllTags["TIT2"]; pt == nil || pt.Value != tc.title || pt.Origin != OriginID3v2 {
+			t.Errorf("%s: TIT2 %+v", tc.name, pt)
+		}
+		if mi.AudioStart != int64(len(b)-len(audio)-len(v1)) || mi.BitRate != 128 {
+			t.Errorf("%s: audio at %d, bitrate %d", tc.name, mi.AudioStart, mi.BitRate)
+		}
+		if tag.Padding != 0 {
+			t.Errorf("%s: tag changed (padding %d)", tc.name, tag.Padding)
+		}
+	}
+}
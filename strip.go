@@ -0,0 +1,183 @@
+package id3v2
+
+import (
+	"io"
+	"os"
+	"sort"
+)
+
+// StripFlags tell which kinds of tags StripTags removes.
+type StripFlags int
+
+const (
+	StripID3v2   StripFlags = 1 << iota // ID3v2 tags at the beginning and at the end of the file
+	StripID3v1                          // ID3v1 tag, its TAG+ block and the Lyrics3v2 tag that goes with it
+	StripAPE                            // APE tag
+	StripLyrics3                        // Lyrics3v2 tag
+	StripAll     = StripID3v2 | StripID3v1 | StripAPE | StripLyrics3
+)
+
+// A byte range of a file, from off to end.
+type span struct {
+	off, end int64
+}
+
+// StripTags removes some kinds of tags from an MP3 file, leaving its audio
+// data untouched. Junk before the ID3v2 tags, if any, and tags that SEEK
+// frames point to, in the audio data, are kept.
+// When only tags at the end of the file are removed, the file is updated in
+// place. Otherwise, it is rewritten as by SaveTags. The modification time of
+// the file is preserved.
+func StripTags(path string, what StripFlags) error {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	mi, err := ReadAllTags(f, fi.Size())
+	if err != nil {
+		return err
+	}
+
+	// List the byte ranges to remove.
+	var cut []span
+	if what&StripID3v2 != 0 {
+		for _, tl := range mi.Layouts {
+			if tl.Offset < mi.AudioStart || tl.Appended {
+				cut = append(cut, span{tl.Offset, tl.End()})
+			}
+		}
+	}
+	if what&StripID3v1 != 0 && mi.ID3v1 != nil {
+		cut = append(cut, span{fi.Size() - mi.ID3v1.Size(), fi.Size()})
+		what |= StripLyrics3 // readers only look for it before an ID3v1 tag
+	}
+	if what&StripAPE != 0 && mi.APE != nil {
+		cut = append(cut, span{mi.APE.Offset, mi.APE.Offset + mi.APE.Size})
+	}
+	if what&StripLyrics3 != 0 && mi.Lyrics3 != nil {
+		cut = append(cut, span{mi.Lyrics3.Offset, mi.Lyrics3.Offset + mi.Lyrics3.Size})
+	}
+	if len(cut) == 0 {
+		return nil
+	}
+	sort.Slice(cut, func(i, j int) bool { return cut[i].off < cut[j].off })
+
+	// List the byte ranges to keep.
+	var keep []span
+	var pos int64
+	for _, c := range cut {
+		if c.off > pos {
+			keep = append(keep, span{pos, c.off})
+		}
+		if c.end > pos {
+			pos = c.end
+		}
+	}
+	if pos < fi.Size() {
+		keep = append(keep, span{pos, fi.Size()})
+	}
+
+	// Tags at the end of the file: move what follows the first range removed
+	// (the tags that are kept), and truncate the file.
+	if cut[0].off >= mi.AudioStart {
+		var tail []byte
+		for _, k := range keep {
+			if k.off > cut[0].off {
+				b := make([]byte, k.end-k.off)
+				if _, err = f.ReadAt(b, k.off); err != nil {
+					return err
+				}
+				tail = append(tail, b...)
+			}
+		}
+		if _, err = f.WriteAt(tail, cut[0].off); err != nil {
+			return err
+		}
+		if err = f.Truncate(cut[0].off + int64(len(tail))); err != nil {
+			return err
+		}
+		if err = f.Close(); err != nil {
+			return err
+		}
+		return os.Chtimes(path, fi.ModTime(), fi.ModTime())
+	}
+	return rewrite(path, f, fi, func(w io.Writer) error {
+		for _, k := range keep {
+			if _, err := io.Copy(w, io.NewSectionReader(f, k.off, k.end-k.off)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// RemoveFrames removes the frames of the tag for which match returns true,
+// but the read-only ones unless Force is set, and returns how many frames
+// were removed.
+func (t *Tag) RemoveFrames(match func(pt *ProcessedTag) bool) int {
+	t.alter()
+	return t.removeFrames(func(pt *ProcessedTag) bool {
+		return match(pt) && t.editable(func(f *ProcessedTag) bool { return f == pt })
+	})
+}
+
+// Remove the frames of the tag for which match returns true, and return how
+// many frames were removed.
+func (t *Tag) removeFrames(match func(pt *ProcessedTag) bool) int {
+	kept := t.Frames[:0]
+	for _, pt := range t.Frames {
+		if !match(pt) {
+			kept = append(kept, pt)
+		}
+	}
+	n := len(t.Frames) - len(kept)
+	for i := len(kept); i < len(t.Frames); i++ {
+		t.Frames[i] = nil
+	}
+	t.Frames = kept
+	return n
+}
+
+// MatchID returns a function that matches the frames of some IDs (eg, all
+// the PRIV and COMM frames), for RemoveFrames and StripFrames.
+func MatchID(ids ...string) func(pt *ProcessedTag) bool {
+	return func(pt *ProcessedTag) bool {
+		for _, id := range ids {
+			if pt.ID == id {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// MatchPOPM returns a function that matches the POPM frames of a user, for
+// RemoveFrames and StripFrames.
+func MatchPOPM(email string) func(pt *ProcessedTag) bool {
+	return func(pt *ProcessedTag) bool {
+		p, ok := pt.Data.(*Popularimeter)
+		return ok && pt.ID == "POPM" && p.Email == email
+	}
+}
+
+// StripFrames removes the ID3v2 frames of an MP3 file for which match
+// returns true, but the read-only ones, and returns how many frames were
+// removed. The tag is saved with SaveTags, so that the file is updated in
+// place: the space of the frames removed becomes padding.
+func StripFrames(path string, match func(pt *ProcessedTag) bool) (int, error) {
+	mi, err := ProcessAllTags(path)
+	if err != nil {
+		return 0, err
+	}
+	t := mi.Tag()
+	n := t.RemoveFrames(match)
+	if n == 0 {
+		return 0, nil
+	}
+	return n, SaveTags(path, t)
+}
@@ -0,0 +1,119 @@
+package id3v2
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStripTags(t *testing.T) {
+	audio := append(append([]byte{}, mpegFrame...), bytes.Repeat([]byte{0xaa}, 1000)...)
+	junk := []byte("junk")
+	v2 := encodeFrames(t, 3, false, newTextFrame("TIT2", "Title", "v2 title"))
+	appended := encodeFrames(t, 4, true, newTextFrame("TPE1", "Artist(s)", "v2 artist"))
+	ape := apeTag(true, APEItem{Key: "Album", Value: []byte("APE album")})
+	l3 := lyrics3Tag("LYR", "La la la")
+	v1 := v1Tag("v1 title", "v1 artist", "", "", "", 0, 255)
+	file := bytes.Join([][]byte{junk, v2, audio, appended, ape, l3, v1}, nil)
+	for _, tc := range []struct {
+		what StripFlags
+		want [][]byte // what is left around the audio data
+	}{
+		{StripAll, [][]byte{junk, audio}},
+		{StripID3v2, [][]byte{junk, audio, ape, l3, v1}},
+		{StripID3v1, [][]byte{junk, v2, audio, appended, ape}}, // the Lyrics3v2 tag goes with it
+		{StripAPE, [][]byte{junk, v2, audio, appended, l3, v1}},
+		{StripLyrics3, [][]byte{junk, v2, audio, appended, ape, v1}},
+		{StripAPE | StripLyrics3, [][]byte{junk, v2, audio, appended, v1}},
+		{StripID3v2 | StripID3v1, [][]byte{junk, audio, ape}},
+	} {
+		path := filepath.Join(t.TempDir(), "test.mp3")
+		if err := os.WriteFile(path, file, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := StripTags(path, tc.what); err != nil {
+			t.Fatalf("0x%x: %s", tc.what, err)
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(b, bytes.Join(tc.want, nil)) {
+			t.Errorf("0x%x: %d bytes left, want %d", tc.what, len(b), len(bytes.Join(tc.want, nil)))
+			continue
+		}
+		mi, err := ReadAllTags(bytes.NewReader(b), int64(len(b)))
+		if err != nil {
+			t.Fatalf("0x%x: %s", tc.what, err)
+		}
+
+		// Without an ID3v2 tag, the junk is taken for the audio data.
+		if bytes.Contains(b, v2) && (mi.AudioStart != int64(len(junk)+len(v2)) || mi.BitRate != 128) {
+			t.Errorf("0x%x: audio at %d, bitrate %d", tc.what, mi.AudioStart, mi.BitRate)
+		}
+		if (mi.Lyrics3 != nil) != bytes.Contains(b, l3) || (mi.APE != nil) != bytes.Contains(b, ape) {
+			t.Errorf("0x%x: Lyrics3v2 tag %+v, APE tag %+v", tc.what, mi.Lyrics3, mi.APE)
+		}
+	}
+
+	// Nothing to strip.
+	path := filepath.Join(t.TempDir(), "test.mp3")
+	if err := os.WriteFile(path, audio, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := StripTags(path, StripAll); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := os.ReadFile(path); err != nil || !bytes.Equal(b, audio) {
+		t.Errorf("audio data altered (%v)", err)
+	}
+}
+
+func TestStripFrames(t *testing.T) {
+	audio := append(append([]byte{}, mpegFrame...), bytes.Repeat([]byte{0xaa}, 1000)...)
+	tag := &Tag{Version: 4, Padding: 10}
+	tag.SetTitle("Title")
+	tag.AddComment("eng", "", "Comment")
+	tag.SetRating("me@example.com", 200)
+	tag.SetRating("you@example.com", 100)
+	b, err := tag.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "test.mp3")
+	if err := os.WriteFile(path, append(b, audio...), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		match func(pt *ProcessedTag) bool
+		n     int
+	}{
+		{MatchID("COMM", "PRIV"), 1},
+		{MatchPOPM("you@example.com"), 1},
+		{MatchID("COMM"), 0},
+	} {
+		n, err := StripFrames(path, tc.match)
+		if err != nil || n != tc.n {
+			t.Fatalf("%d frames stripped (%v), want %d", n, err, tc.n)
+		}
+	}
+	f, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f) != len(b)+len(audio) || !bytes.HasSuffix(f, audio) {
+		t.Errorf("file rewritten: %d bytes", len(f))
+	}
+	mi, err := ReadAllTags(bytes.NewReader(f), int64(len(f)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, pt := range mi.Frames {
+		ids = append(ids, pt.ID)
+	}
+	if len(ids) != 2 || ids[0] != "TIT2" || !MatchPOPM("me@example.com")(mi.Frames[1]) {
+		t.Errorf("frames %q", ids)
+	}
+}
@@ -0,0 +1,172 @@
+package id3v2
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// LinkedInfo is the decoded content of a LINK (linked information) frame,
+// which tells that a frame is to be found in another file.
+type LinkedInfo struct {
+	FrameID string   // ID of the linked frame
+	URL     string   // URL of the file where the frame is
+	Data    []string // ID and additional data identifying the frame in the file
+}
+
+// Position is the decoded content of a POSS (position synchronisation)
+// frame: the position of the beginning of the file in the whole audio.
+type Position struct {
+	Format   byte   // time stamp format (1: MPEG frames, 2: milliseconds)
+	Position uint64 // position, in Format units
+}
+
+// String returns the position with its unit.
+func (p *Position) String() string {
+	switch p.Format {
+	case 1:
+		return fmt.Sprintf("%d MPEG frames", p.Position)
+	case 2:
+		return fmt.Sprintf("%d ms", p.Position)
+	default:
+		return fmt.Sprintf("%d (unknown format 0x%02x)", p.Position, p.Format)
+	}
+}
+
+// RecommendedBuffer is the decoded content of an RBUF (recommended buffer
+// size) frame.
+type RecommendedBuffer struct {
+	Size     uint32 // buffer size (24 bits)
+	Embedded bool   // ID3 tags may be embedded in the audio stream
+	NextTag  uint32 // offset to the next embedded tag, or 0
+}
+
+// TagSeek is the decoded content of a SEEK frame: the minimum offset from
+// the end of this tag to the beginning of the next one.
+type TagSeek struct {
+	Offset uint32
+}
+
+// AudioSeekIndex is the decoded content of an ASPI (audio seek point index)
+// frame. The indexed data is split in len(Points) parts of equal duration,
+// each point being the relative position of the beginning of a part.
+type AudioSeekIndex struct {
+	Start        uint32   // indexed data start, relative to the end of the tag
+	Length       uint32   // indexed data length, in bytes
+	BitsPerPoint uint8    // 8 or 16
+	Points       []uint16 // fractions of Length, in units of 1/2^BitsPerPoint
+}
+
+// Offset returns the position, relative to the end of the tag, of the i-th
+// index point.
+func (a *AudioSeekIndex) Offset(i int) uint32 {
+	return a.Start + uint32(uint64(a.Points[i])*uint64(a.Length)>>a.BitsPerPoint)
+}
+
+// Decode the payload of a LINK frame.
+//
+//	Frame identifier        $xx xx xx (xx in ID3v2.4)
+//	URL                     <text string> $00
+//	ID and additional data  <text string(s)>
+func parseLINK(pl []byte, ver byte) (*LinkedInfo, error) {
+	idl := 3 // the frame identifier is 3-byte long in the ID3v2.3 spec
+	if ver >= 4 {
+		idl = 4
+	}
+	if len(pl) < idl {
+		return nil, errors.New(fmt.Sprintf("LINK frame too short (%d bytes)", len(pl)))
+	}
+	l := &LinkedInfo{FrameID: string(pl[:idl])}
+	url, rest, _ := splitString(0x00, pl[idl:])
+	l.URL = decodeISO8859(url)
+	rest = bytes.TrimRight(rest, "\x00")
+	if len(rest) > 0 {
+		for _, d := range bytes.Split(rest, []byte{0x00}) {
+			l.Data = append(l.Data, decodeISO8859(d))
+		}
+	}
+	return l, nil
+}
+
+// Decode the payload of a POSS frame.
+//
+//	Time stamp format  $xx
+//	Position           $xx (xx ...)
+func parsePOSS(pl []byte) (*Position, error) {
+	if len(pl) < 2 || len(pl) > 9 {
+		return nil, errors.New(fmt.Sprintf("Invalid POSS frame size (%d bytes)", len(pl)))
+	}
+	p := &Position{Format: pl[0]}
+	for _, b := range pl[1:] {
+		p.Position = p.Position<<8 | uint64(b)
+	}
+	return p, nil
+}
+
+// Decode the payload of an RBUF frame.
+//
+//	Buffer size              $xx xx xx
+//	Embedded info flag       %0000000x
+//	Offset to next tag       $xx xx xx xx (optional)
+func parseRBUF(pl []byte) (*RecommendedBuffer, error) {
+	if len(pl) < 4 {
+		return nil, errors.New(fmt.Sprintf("RBUF frame too short (%d bytes)", len(pl)))
+	}
+	r := &RecommendedBuffer{
+		Size:     uint32(pl[0])<<16 | uint32(pl[1])<<8 | uint32(pl[2]),
+		Embedded: pl[3]&0x01 != 0,
+	}
+	if len(pl) >= 8 {
+		r.NextTag = uint32(pl[4])<<24 | uint32(pl[5])<<16 | uint32(pl[6])<<8 | uint32(pl[7])
+	}
+	return r, nil
+}
+
+// Decode the payload of a SEEK frame.
+//
+//	Minimum offset to next tag  $xx xx xx xx
+func parseSEEK(pl []byte) (*TagSeek, error) {
+	if len(pl) < 4 {
+		return nil, errors.New(fmt.Sprintf("SEEK frame too short (%d bytes)", len(pl)))
+	}
+	return &TagSeek{Offset: uint32(pl[0])<<24 | uint32(pl[1])<<16 | uint32(pl[2])<<8 | uint32(pl[3])}, nil
+}
+
+// Decode the payload of an ASPI frame.
+//
+//	Indexed data start (S)        $xx xx xx xx
+//	Indexed data length (L)       $xx xx xx xx
+//	Number of index points (N)    $xx xx
+//	Bits per index point (b)      $xx
+//
+// Then for every index point:
+//
+//	Fraction at index (Fi)        $xx (xx)
+func parseASPI(pl []byte) (*AudioSeekIndex, error) {
+	if len(pl) < 11 {
+		return nil, errors.New(fmt.Sprintf("ASPI frame too short (%d bytes)", len(pl)))
+	}
+	a := &AudioSeekIndex{
+		Start:        uint32(pl[0])<<24 | uint32(pl[1])<<16 | uint32(pl[2])<<8 | uint32(pl[3]),
+		Length:       uint32(pl[4])<<24 | uint32(pl[5])<<16 | uint32(pl[6])<<8 | uint32(pl[7]),
+		BitsPerPoint: pl[10],
+	}
+	n := int(pl[8])<<8 | int(pl[9])
+	if a.BitsPerPoint != 8 && a.BitsPerPoint != 16 {
+		return nil, errors.New(fmt.Sprintf("Invalid ASPI bits per index point (%d)", a.BitsPerPoint))
+	}
+	bpp := int(a.BitsPerPoint) / 8
+	pl = pl[11:]
+	if len(pl) < n*bpp {
+		return nil, errors.New(fmt.Sprintf("Truncated ASPI frame (%d index points expected)", n))
+	}
+	a.Points = make([]uint16, n)
+	for i := range a.Points {
+		if bpp == 1 {
+			a.Points[i] = uint16(pl[i])
+		} else {
+			a.Points[i] = uint16(pl[2*i])<<8 | uint16(pl[2*i+1])
+		}
+	}
+	return a, nil
+}
@@ -0,0 +1,57 @@
+package id3v2
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStructureFrames(t *testing.T) {
+	for _, tc := range []struct {
+		ver  byte
+		id   string
+		pl   string
+		want interface{}
+	}{
+		{3, "LINK", "TIThttp://example.com/info.mp3\x00TIT2\x00more\x00", &LinkedInfo{FrameID: "TIT", URL: "http://example.com/info.mp3", Data: []string{"TIT2", "more"}}},
+		{4, "LINK", "TIT2http://example.com/info.mp3\x00", &LinkedInfo{FrameID: "TIT2", URL: "http://example.com/info.mp3"}},
+		{4, "POSS", "\x02\x01\x00\x00", &Position{Format: 2, Position: 0x10000}},
+		{4, "RBUF", "\x01\x00\x00\x01", &RecommendedBuffer{Size: 0x10000, Embedded: true}},
+		{3, "RBUF", "\x00\x10\x00\x00\x00\x00\x20\x00", &RecommendedBuffer{Size: 0x1000, NextTag: 0x2000}},
+		{4, "SEEK", "\x00\x01\x00\x00", &TagSeek{Offset: 0x10000}},
+		{4, "ASPI", "\x00\x00\x00\x10\x00\x00\x10\x00\x00\x03\x08\x00\x40\x80", &AudioSeekIndex{Start: 16, Length: 4096, BitsPerPoint: 8, Points: []uint16{0, 0x40, 0x80}}},
+		{4, "ASPI", "\x00\x00\x00\x00\x00\x01\x00\x00\x00\x02\x10\x00\x00\x80\x00", &AudioSeekIndex{Length: 0x10000, BitsPerPoint: 16, Points: []uint16{0, 0x8000}}},
+	} {
+		mi := readFrames(t, tc.ver, rawFrame(tc.id, []byte(tc.pl)))
+		pt := mi.AllTags[tc.id]
+		if pt == nil || pt.Err != nil || !reflect.DeepEqual(pt.Data, tc.want) {
+			t.Errorf("ID3v2.%d %s: %+v, want %+v", tc.ver, tc.id, pt, tc.want)
+		}
+	}
+
+	if s := (&Position{Format: 1, Position: 12}).String(); s != "12 MPEG frames" {
+		t.Errorf("position %q", s)
+	}
+	a := &AudioSeekIndex{Start: 16, Length: 4096, BitsPerPoint: 8, Points: []uint16{0, 0x40, 0x80}}
+	if a.Offset(1) != 16+1024 || a.Offset(2) != 16+2048 {
+		t.Errorf("ASPI offsets %d, %d", a.Offset(1), a.Offset(2))
+	}
+}
+
+func TestStructureFramesInvalid(t *testing.T) {
+	for _, tc := range []struct {
+		id string
+		pl string
+	}{
+		{"LINK", "TI"},
+		{"POSS", "\x02"},
+		{"RBUF", "\x00\x10"},
+		{"SEEK", "\x00\x01"},
+		{"ASPI", "\x00\x00\x00\x00\x00\x00\x10\x00\x00\x03\x0c\x00\x40\x80"}, // 12 bits per point
+		{"ASPI", "\x00\x00\x00\x00\x00\x00\x10\x00\x00\x04\x08\x00\x40\x80"}, // truncated
+	} {
+		mi := readFrames(t, 4, rawFrame(tc.id, []byte(tc.pl)))
+		if pt := mi.AllTags[tc.id]; pt.Err == nil || pt.Data != nil {
+			t.Errorf("%s %q: %+v", tc.id, tc.pl, pt)
+		}
+	}
+}
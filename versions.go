@@ -0,0 +1,134 @@
+package id3v2
+
+import (
+	"strings"
+)
+
+// Version-specific frames: tagmap holds the frames of all the ID3v2
+// versions, under their ID3v2.3/ID3v2.4 IDs; these tables tell which ones
+// only exist in one version, and how ID3v2.2 frames map to them.
+var (
+	// ID3v2.2 frame IDs and the matching ID3v2.3 ones.
+	v22IDs = map[string]string{
+		"BUF": "RBUF", // Recommended buffer size
+		"CNT": "PCNT", // Play counter
+		"COM": "COMM", // Comments
+		"CRA": "AENC", // Audio encryption
+		"EQU": "EQUA", // Equalization
+		"ETC": "ETCO", // Event timing codes
+		"GEO": "GEOB", // General encapsulated object
+		"IPL": "IPLS", // Involved people list
+		"LNK": "LINK", // Linked information
+		"MCI": "MCDI", // Music CD identifier
+		"MLL": "MLLT", // MPEG location lookup table
+		"PIC": "APIC", // Attached picture
+		"POP": "POPM", // Popularimeter
+		"REV": "RVRB", // Reverb
+		"RVA": "RVAD", // Relative volume adjustment
+		"SLT": "SYLT", // Synchronized lyric/text
+		"STC": "SYTC", // Synchronized tempo codes
+		"TAL": "TALB", // Album/Movie/Show title
+		"TBP": "TBPM", // BPM (beats per minute)
+		"TCM": "TCOM", // Composer
+		"TCO": "TCON", // Content type
+		"TCR": "TCOP", // Copyright message
+		"TDA": "TDAT", // Date
+		"TDY": "TDLY", // Playlist delay
+		"TEN": "TENC", // Encoded by
+		"TFT": "TFLT", // File type
+		"TIM": "TIME", // Time
+		"TKE": "TKEY", // Initial key
+		"TLA": "TLAN", // Language(s)
+		"TLE": "TLEN", // Length
+		"TMT": "TMED", // Media type
+		"TOA": "TOPE", // Original artist(s)/performer(s)
+		"TOF": "TOFN", // Original filename
+		"TOL": "TOLY", // Original lyricist(s)/text writer(s)
+		"TOR": "TORY", // Original release year
+		"TOT": "TOAL", // Original album/movie/show title
+		"TP1": "TPE1", // Lead performer(s)/Soloist(s)
+		"TP2": "TPE2", // Band/orchestra/accompaniment
+		"TP3": "TPE3", // Conductor/performer refinement
+		"TP4": "TPE4", // Interpreted, remixed, or otherwise modified by
+		"TPA": "TPOS", // Part of a set
+		"TPB": "TPUB", // Publisher
+		"TRC": "TSRC", // ISRC (international standard recording code)
+		"TRD": "TRDA", // Recording dates
+		"TRK": "TRCK", // Track number/Position in set
+		"TSI": "TSIZ", // Size
+		"TSS": "TSSE", // Software/Hardware and settings used for encoding
+		"TT1": "TIT1", // Content group description
+		"TT2": "TIT2", // Title/songname/content description
+		"TT3": "TIT3", // Subtitle/Description refinement
+		"TXT": "TEXT", // Lyricist/text writer
+		"TXX": "TXXX", // User defined text information frame
+		"TYE": "TYER", // Year
+		"UFI": "UFID", // Unique file identifier
+		"ULT": "USLT", // Unsychronized lyric/text transcription
+		"WAF": "WOAF", // Official audio file webpage
+		"WAR": "WOAR", // Official artist/performer webpage
+		"WAS": "WOAS", // Official audio source webpage
+		"WCM": "WCOM", // Commercial information
+		"WCP": "WCOP", // Copyright/Legal information
+		"WPB": "WPUB", // Publishers official webpage
+		"WXX": "WXXX", // User defined URL link frame
+	}
+
+	// Frames of ID3v2.2 and ID3v2.3 that ID3v2.4 dropped.
+	v23Only = map[string]bool{
+		"EQUA": true, // replaced by EQU2
+		"IPLS": true, // replaced by TIPL and TMCL
+		"RVAD": true, // replaced by RVA2
+		"TDAT": true, // replaced by TDRC
+		"TIME": true, // replaced by TDRC
+		"TORY": true, // replaced by TDOR
+		"TRDA": true, // replaced by TDRC
+		"TSIZ": true,
+		"TYER": true, // replaced by TDRC
+	}
+
+	// Frames new in ID3v2.4.
+	v24Only = map[string]bool{
+		"ASPI": true,
+		"EQU2": true,
+		"RVA2": true,
+		"SEEK": true,
+		"SIGN": true,
+		"TDEN": true,
+		"TDOR": true,
+		"TDRC": true,
+		"TDRL": true,
+		"TDTG": true,
+		"TIPL": true,
+		"TMCL": true,
+		"TMOO": true,
+		"TPRO": true,
+		"TSOA": true,
+		"TSOP": true,
+		"TSOT": true,
+		"TSST": true,
+	}
+)
+
+// Convert the payload of an ID3v2.2 PIC frame, whose image format is a
+// 3-character code, to the payload of an APIC frame, whose image format is
+// a MIME type.
+func picToAPIC(pl []byte) []byte {
+	if len(pl) < 4 {
+		return pl
+	}
+	var mime string
+	switch f := string(pl[1:4]); f {
+	case "JPG":
+		mime = "image/jpeg"
+	case "PNG":
+		mime = "image/png"
+	case "-->":
+		mime = "-->" // the picture is a link
+	default:
+		mime = "image/" + strings.ToLower(strings.TrimRight(f, " \x00"))
+	}
+	b := append([]byte{pl[0]}, mime...)
+	b = append(b, 0x00)
+	return append(b, pl[4:]...)
+}
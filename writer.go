@@ -0,0 +1,386 @@
+package id3v2
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"strings"
+	"unicode/utf16"
+)
+
+// Tag is an ID3v2 tag to be written, made of frames as returned by the
+// processing of a file (see MP3Info.Tag) and possibly edited.
+//
+// The payload of the text frames (T*** frames, TXXX included) that are new,
+// set through the methods of the tag, converted by ConvertTo or whose Value
+// has been changed (it no longer matches Values) is built from their values:
+// Values, or Value alone if it has been changed. The text encoding is
+// ISO-8859-1 when possible, and UTF-8 (ID3v2.4) or UTF-16 (ID3v2.3)
+// otherwise. The payload of the other frames, the text frames read from a
+// file and left untouched included, is written as is.
+//
+// Unknown frames (see ProcessedTag.Known) are written as is as well, with
+// their flags, unless their tag alter preservation flag is set: such frames
+// are discarded when the tag is altered, which editing or writing it does.
+// Their file alter preservation flag is honoured by AudioAltered.
+//
+// The methods that edit a tag (setters, RemoveFrames, SetPicture, etc) do
+// not change or remove its read-only frames, unless Force is set. The frames
+// they discard, protect or change although read-only are listed in Report.
+type Tag struct {
+	Version byte // major version: 3 for ID3v2.3.0, 4 for ID3v2.4.0
+	Frames  []*ProcessedTag
+	Padding int  // bytes of padding after the frames
+	Unsync  bool // apply the unsynchronisation scheme, where needed
+	Footer  bool // end the tag with a footer (ID3v2.4, no padding then)
+	CRC     bool // write an extended header with a CRC-32 of the tag data
+
+	Force  bool     // let the edits change and remove read-only frames
+	Report []string // frames affected by the preservation flags, one per line
+
+	altered bool // unknown frames have been discarded on alteration
+}
+
+// Tag returns the frames of the ID3v2 tags at the beginning of the file
+// (not the ones of the tags at the end of the file, or that SEEK frames point
+// to) as a tag that can be edited and encoded. Its version is the one of the
+// first ID3v2 tag of the file, or ID3v2.3 if there is none. ID3v2.2 tags,
+// whose frames have their ID3v2.3 IDs, must be converted (see ConvertTo)
+// before they are encoded.
+func (mi *MP3Info) Tag() *Tag {
+	t := &Tag{Version: 3}
+	if len(mi.Layouts) > 0 {
+		if v := mi.Layouts[0].Version; v == 2 || v == 4 {
+			t.Version = v
+		}
+		if xh := mi.Layouts[0].ExtendedHeader; xh != nil && xh.HasCRC {
+			t.CRC = true
+		}
+	}
+	for _, pt := range mi.Frames {
+		if pt.Origin == OriginID3v2 && (pt.tag == nil || pt.tag.Offset < mi.AudioStart) {
+			t.Frames = append(t.Frames, pt)
+		}
+	}
+	return t
+}
+
+// AudioAltered removes the unknown frames of a tag whose file alter
+// preservation flag is set, which are to be discarded when the audio data of
+// the file is altered (eg, re-encoded or cut), and returns how many frames
+// were removed. Tools that alter the audio data should call it before
+// writing the tag back.
+func (t *Tag) AudioAltered() int {
+	return t.discard(flgFap, "the audio is altered")
+}
+
+// Record that a tag is altered: its unknown frames whose tag alter
+// preservation flag is set are discarded, once.
+func (t *Tag) alter() {
+	if !t.altered {
+		t.altered = true
+		t.discard(flgTap, "the tag is altered")
+	}
+}
+
+// Remove the unknown frames of a tag that have a preservation flag set, and
+// return how many frames were removed.
+func (t *Tag) discard(flag uint16, reason string) int {
+	return t.removeFrames(func(pt *ProcessedTag) bool {
+		if pt.Known() || pt.Flags&flag == 0 {
+			return false
+		}
+		t.report(pt.ID, "discarded, %s", reason)
+		return true
+	})
+}
+
+// Add a line about a frame to the report of a tag.
+func (t *Tag) report(id, format string, a ...interface{}) {
+	t.Report = append(t.Report, id+": "+fmt.Sprintf(format, a...))
+}
+
+// Tell if the frames of a tag for which match returns true may be changed:
+// they may not if one of them is read-only, unless Force is set.
+func (t *Tag) editable(match func(pt *ProcessedTag) bool) bool {
+	ok := true
+	for _, pt := range t.Frames {
+		if !match(pt) || pt.Flags&flgRO == 0 {
+			continue
+		}
+		if t.Force {
+			t.report(pt.ID, "read-only frame changed (forced)")
+		} else {
+			t.report(pt.ID, "read-only frame kept")
+			ok = false
+		}
+	}
+	return ok
+}
+
+// Tell if a frame is a text frame, whose payload is built from its values.
+// The payload of a frame that could not be decoded (eg, an unknown text
+// encoding) is kept as is.
+func isTextFrame(pt *ProcessedTag) bool {
+	return len(pt.ID) == 4 && pt.ID[0] == 'T' && !pt.Encrypted() && pt.Known() && pt.Err == nil
+}
+
+// Tell if the payload of a text frame is to be built from its values: the
+// frame is new, edited or converted, or its Value has been changed.
+func textEdited(pt *ProcessedTag) bool {
+	return pt.edited || len(pt.Payload) == 0 || strings.Join(pt.Values, "/") != pt.Value
+}
+
+// Return the values a text frame is to be written with.
+func textValues(pt *ProcessedTag) []string {
+	if pt.Values == nil && pt.Value == "" || strings.Join(pt.Values, "/") == pt.Value {
+		return pt.Values
+	}
+	return []string{pt.Value} // Value was changed
+}
+
+// Choose the text encoding of strings for an ID3v2 version.
+func textEncoding(ver byte, values ...string) byte {
+	for _, v := range values {
+		for _, c := range v {
+			if c > 0xff {
+				if ver >= 4 {
+					return 0x03 // UTF-8
+				}
+				return 0x01 // UTF-16 with BOM
+			}
+		}
+	}
+	return 0x00 // ISO 8859-1
+}
+
+// Encode a string according to a text encoding byte, without terminator.
+// The characters out of ISO-8859-1 are written as '?' in ISO-8859-1.
+func encodeString(et byte, s string) []byte {
+	switch et {
+	case 0x00:
+		b := make([]byte, 0, len(s))
+		for _, c := range s {
+			if c > 0xff {
+				c = '?'
+			}
+			b = append(b, byte(c))
+		}
+		return b
+	case 0x01, 0x02:
+		u := utf16.Encode([]rune(s))
+		var b []byte
+		if et == 0x01 {
+			b = append(b, 0xff, 0xfe) // little-endian BOM
+		}
+		for _, c := range u {
+			if et == 0x01 {
+				b = append(b, byte(c), byte(c>>8))
+			} else {
+				b = append(b, byte(c>>8), byte(c))
+			}
+		}
+		return b
+	default:
+		return []byte(s)
+	}
+}
+
+// Return the terminator of the strings of a text encoding.
+func terminator(et byte) []byte {
+	if et == 0x01 || et == 0x02 {
+		return []byte{0x00, 0x00}
+	}
+	return []byte{0x00}
+}
+
+// Build the payload of a text frame. ID3v2.4 text frames hold their values
+// separated by terminators. ID3v2.3 text frames only hold one string: the
+// values are joined with '/', except the description of a TXXX frame.
+func textPayload(ver byte, id string, values []string) []byte {
+	if ver < 4 && len(values) > 1 {
+		if id == "TXXX" {
+			values = []string{values[0], strings.Join(values[1:], "/")}
+		} else {
+			values = []string{strings.Join(values, "/")}
+		}
+	}
+	et := textEncoding(ver, values...)
+	return append([]byte{et}, encodeStrings(et, values)...)
+}
+
+// Encode strings according to a text encoding byte, separated by
+// terminators.
+func encodeStrings(et byte, values []string) []byte {
+	var b []byte
+	for i, v := range values {
+		if i > 0 {
+			b = append(b, terminator(et)...)
+		}
+		b = append(b, encodeString(et, v)...)
+	}
+	return b
+}
+
+// Apply the unsynchronisation scheme to b: a $00 is inserted after every
+// $FF followed by %111xxxxx or $00, and after a final $FF.
+func unsync(b []byte) []byte {
+	d := make([]byte, 0, len(b)+len(b)/64)
+	for i, c := range b {
+		d = append(d, c)
+		if c == 0xff && (i+1 == len(b) || b[i+1]&0xe0 == 0xe0 || b[i+1] == 0x00) {
+			d = append(d, 0x00)
+		}
+	}
+	return d
+}
+
+// Tell if b needs unsynchronisation.
+func needsUnsync(b []byte) bool {
+	for i, c := range b {
+		if c == 0xff && (i+1 == len(b) || b[i+1]&0xe0 == 0xe0 || b[i+1] == 0x00) {
+			return true
+		}
+	}
+	return false
+}
+
+// Convert frame flags from the ID3v2.3 layout (see mp3Tag) to the ID3v2.4
+// layout (0abc0000 0h00kmnp).
+func v24RawFlags(f uint16) uint16 {
+	return (f&0xe000)>>1 | // abc
+		(f&0x0020)<<1 | // k -> h
+		(f&0x0080)>>4 | // i -> k
+		(f&0x0040)>>4 | // j -> m
+		(f & 0x0003) // n, p
+}
+
+// Return a 28-bit integer as 4 syncsafe bytes.
+func syncsafeBytes(n uint) []byte {
+	return []byte{byte(n >> 21 & 0x7f), byte(n >> 14 & 0x7f), byte(n >> 7 & 0x7f), byte(n & 0x7f)}
+}
+
+// Encode a frame: header, extra bytes and payload.
+func (t *Tag) encodeFrame(pt *ProcessedTag) ([]byte, error) {
+	if len(pt.ID) != 4 || !validFrameID([]byte(pt.ID)) {
+		return nil, errors.New(fmt.Sprintf("Invalid frame ID %q", pt.ID))
+	}
+	pl := pt.Payload
+	if isTextFrame(pt) && textEdited(pt) {
+		pl = textPayload(t.Version, pt.ID, textValues(pt))
+	}
+	flags := pt.Flags &^ (flgUnsync | flgDataLength)
+	size := uint(len(pl))
+	if flags&flgEncrypted != 0 { // the payload is compressed already, if needed
+		size = pt.uncSize
+	} else if flags&flgCompressed != 0 {
+		var z bytes.Buffer
+		zw := zlib.NewWriter(&z)
+		zw.Write(pl)
+		zw.Close()
+		pl = z.Bytes()
+	}
+
+	var extra []byte
+	if t.Version >= 4 {
+		if flags&flgGroupId != 0 {
+			extra = append(extra, pt.GroupID)
+		}
+		if flags&flgEncrypted != 0 {
+			extra = append(extra, pt.EncMethod)
+		}
+		// Compressed frames need a data length indicator, and encrypted ones
+		// keep theirs.
+		if flags&flgCompressed != 0 || flags&flgEncrypted != 0 && pt.Flags&flgDataLength != 0 {
+			flags |= flgDataLength
+			extra = append(extra, syncsafeBytes(size)...)
+		}
+		if t.Unsync && needsUnsync(pl) {
+			flags |= flgUnsync
+			pl = unsync(pl)
+		}
+	} else {
+		if flags&flgCompressed != 0 {
+			extra = append(extra, byte(size>>24), byte(size>>16), byte(size>>8), byte(size))
+		}
+		if flags&flgEncrypted != 0 {
+			extra = append(extra, pt.EncMethod)
+		}
+		if flags&flgGroupId != 0 {
+			extra = append(extra, pt.GroupID)
+		}
+	}
+
+	n := uint(len(extra) + len(pl))
+	b := []byte(pt.ID)
+	if t.Version >= 4 {
+		if n >= 1<<28 {
+			return nil, errors.New(fmt.Sprintf("Frame %s too large (%d bytes)", pt.ID, n))
+		}
+		b = append(b, syncsafeBytes(n)...)
+		rf := v24RawFlags(flags)
+		b = append(b, byte(rf>>8), byte(rf))
+	} else {
+		b = append(b, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+		b = append(b, byte(flags>>8), byte(flags))
+	}
+	b = append(b, extra...)
+	return append(b, pl...), nil
+}
+
+// Encode returns the tag as it is to be written in a file: header, frames,
+// padding and footer. As writing a tag alters it, its unknown frames whose
+// tag alter preservation flag is set are discarded first, and reported.
+func (t *Tag) Encode() ([]byte, error) {
+	if t.Version != 3 && t.Version != 4 {
+		return nil, errors.New(fmt.Sprintf("Cannot write ID3v2.%d tags", t.Version))
+	}
+	if t.Padding < 0 || t.Footer && (t.Version < 4 || t.Padding > 0) {
+		return nil, errors.New("Invalid padding or footer")
+	}
+	t.alter()
+	var body []byte
+	var hflags byte
+	for _, pt := range t.Frames {
+		f, err := t.encodeFrame(pt)
+		if err != nil {
+			return nil, err
+		}
+		body = append(body, f...)
+		if t.Version >= 4 && f[9]&0x02 != 0 {
+			hflags |= 0x80 // all the frames that need it are unsynchronised
+		}
+	}
+	if t.Version >= 4 {
+		body = append(body, make([]byte, t.Padding)...)
+		if t.CRC { // the CRC covers the frames and the padding
+			body = append(extHeader24(crc32.ChecksumIEEE(body)), body...)
+			hflags |= 0x40
+		}
+	} else {
+		if t.CRC { // the CRC covers the frames only, before unsynchronisation
+			body = append(extHeader23(uint(t.Padding), crc32.ChecksumIEEE(body)), body...)
+			hflags |= 0x40
+		}
+		if t.Unsync && needsUnsync(body) {
+			body = unsync(body)
+			hflags |= 0x80
+		}
+		body = append(body, make([]byte, t.Padding)...)
+	}
+	if len(body) >= 1<<28 {
+		return nil, errors.New(fmt.Sprintf("Tag too large (%d bytes)", len(body)))
+	}
+	if t.Footer {
+		hflags |= 0x10
+	}
+	h := append([]byte{'I', 'D', '3', t.Version, 0, hflags}, syncsafeBytes(uint(len(body)))...)
+	b := append(h, body...)
+	if t.Footer {
+		b = append(b, '3', 'D', 'I')
+		b = append(b, h[3:]...)
+	}
+	return b, nil
+}
@@ -0,0 +1,232 @@
+package id3v2
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// Return the frames of a tag to be encoded: multiple values, non-ISO-8859-1
+// strings (UTF-16 in ID3v2.3, UTF-8 in ID3v2.4), a compressed frame and a
+// frame that needs unsynchronisation.
+func roundTripFrames() []*ProcessedTag {
+	return []*ProcessedTag{
+		newTextFrame("TIT2", "Title", "Title"),
+		newTextFrame("TPE1", "Artist(s)", "Ärtist", "Артист"),
+		newTextFrame("TXXX", "User defined text", "desc", "v1", "v2"),
+		{Name: "Album", ID: "TALB", Value: "Compressed album", Values: []string{"Compressed album"}, Flags: flgCompressed, Origin: OriginID3v2},
+		{Name: "Private", ID: "PRIV", Payload: []byte("owner\x00\xff\xe0\xff\x00\xff"), Origin: OriginID3v2},
+	}
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	for _, tc := range []struct {
+		ver    byte
+		unsync bool
+		footer bool
+	}{
+		{3, false, false},
+		{3, true, false},
+		{4, false, false},
+		{4, true, false},
+		{4, true, true},
+	} {
+		tag := &Tag{Version: tc.ver, Frames: roundTripFrames(), Unsync: tc.unsync, Footer: tc.footer}
+		if !tc.footer {
+			tag.Padding = 100
+		}
+		b, err := tag.Encode()
+		if err != nil {
+			t.Fatalf("%+v: %s", tc, err)
+		}
+		b = append(b, mpegFrame...)
+		mi, err := ReadAllTags(bytes.NewReader(b), int64(len(b)))
+		if err != nil {
+			t.Fatalf("%+v: %s", tc, err)
+		}
+
+		// Frame values.
+		artists := []string{"Ärtist", "Артист"}
+		txxx := []string{"desc", "v1", "v2"}
+		if tc.ver < 4 { // a single string, values joined with '/'
+			artists = []string{"Ärtist/Артист"}
+			txxx = []string{"desc", "v1/v2"}
+		}
+		for id, want := range map[string][]string{
+			"TIT2": {"Title"},
+			"TPE1": artists,
+			"TXXX": txxx,
+			"TALB": {"Compressed album"},
+		} {
+			pt := mi.AllTags[id]
+			if pt == nil {
+				t.Errorf("%+v: no %s frame", tc, id)
+			} else if !reflect.DeepEqual(pt.Values, want) || pt.Err != nil {
+				t.Errorf("%+v: %s = %q (%v), want %q", tc, id, pt.Values, pt.Err, want)
+			}
+		}
+		if pt := mi.AllTags["TALB"]; pt != nil && pt.Flags&flgCompressed == 0 {
+			t.Errorf("%+v: TALB not compressed (flags 0x%04x)", tc, pt.Flags)
+		}
+		if pt := mi.AllTags["PRIV"]; pt == nil || !bytes.Equal(pt.Payload, roundTripFrames()[4].Payload) {
+			t.Errorf("%+v: PRIV = %v", tc, pt)
+		}
+		if et := mi.AllTags["TPE1"].Payload[0]; tc.ver < 4 && et != 0x01 || tc.ver >= 4 && et != 0x03 {
+			t.Errorf("%+v: TPE1 text encoding 0x%02x", tc, et)
+		}
+
+		// Layout.
+		if len(mi.Layouts) != 1 {
+			t.Fatalf("%+v: %d tags", tc, len(mi.Layouts))
+		}
+		tl := mi.Layouts[0]
+		if tl.Version != tc.ver || tl.HasFooter() != tc.footer || tl.Flags&0x80 != 0 != tc.unsync {
+			t.Errorf("%+v: version %d, flags 0x%02x", tc, tl.Version, tl.Flags)
+		}
+		if len(tl.Frames) != 5 || tl.Padding != uint(tag.Padding) || tl.End() != int64(len(b)-len(mpegFrame)) || mi.AudioStart != tl.End() {
+			t.Errorf("%+v: %d frames, padding %d, end %d, audio at %d", tc, len(tl.Frames), tl.Padding, tl.End(), mi.AudioStart)
+		}
+		for _, fl := range tl.Frames {
+			got, want := fl.Flags, uint16(0)
+			switch {
+			case fl.ID == "TALB" && tc.ver < 4:
+				want = 0x0080 // compression
+			case fl.ID == "TALB":
+				want = 0x0009 // compression, data length indicator
+			case fl.ID == "PRIV" && tc.ver >= 4 && tc.unsync:
+				want = 0x0002 // unsynchronisation
+			}
+			if fl.ID == "TALB" && tc.ver >= 4 {
+				got &^= 0x0002 // the compressed data may need unsynchronisation
+			}
+			if got != want {
+				t.Errorf("%+v: %s flags 0x%04x, want 0x%04x", tc, fl.ID, fl.Flags, want)
+			}
+		}
+		if mi.BitRate != 128 {
+			t.Errorf("%+v: bitrate %d", tc, mi.BitRate)
+		}
+	}
+}
+
+func TestEncodeDiscardsReported(t *testing.T) {
+	tag := &Tag{Version: 3, Frames: []*ProcessedTag{
+		newTextFrame("TIT2", "Title", "Title"),
+		{Name: "Unknown", ID: "XTAP", Payload: []byte("x"), Flags: flgTap, Origin: OriginID3v2},
+		{Name: "Unknown", ID: "XKEP", Payload: []byte("y"), Origin: OriginID3v2},
+	}}
+	b, err := tag.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mi, err := ReadAllTags(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mi.AllTags["XTAP"] != nil || mi.AllTags["XKEP"] == nil {
+		t.Errorf("frames %v", mi.AllTags)
+	}
+	if len(tag.Report) != 1 || len(tag.Frames) != 2 {
+		t.Errorf("report %q, %d frames", tag.Report, len(tag.Frames))
+	}
+}
+
+func TestEncodeEncrypted(t *testing.T) {
+	for _, tc := range []struct {
+		flags byte   // ID3v2.4 frame flags (second byte)
+		pl    string // method symbol, data length indicator if any, data
+	}{
+		{0x04, "\x80\x12\x34\x56\x78\x9a"},
+		{0x05, "\x80\x00\x00\x00\x05\x12\x34\x56\x78\x9a"},     // data length indicator
+		{0x0d, "\x80\x00\x00\x00\x20\x78\x9c\x12\x34\x56"},     // compressed, data length indicator
+		{0x45, "\x01\x80\x00\x00\x00\x05\x12\x34\x56\x78\x9a"}, // grouped
+	} {
+		b := rawTag(4, "TIT2", tc.pl)
+		b[19] = tc.flags
+		mi, err := ReadAllTags(bytes.NewReader(b), int64(len(b)))
+		if err != nil {
+			t.Fatalf("0x%02x: %s", tc.flags, err)
+		}
+		tag := mi.Tag()
+		tag.Padding = 0
+		e, err := tag.Encode()
+		if err != nil {
+			t.Fatalf("0x%02x: %s", tc.flags, err)
+		}
+		if !bytes.Equal(e, b) {
+			t.Errorf("0x%02x: written as %q, want %q", tc.flags, e, b)
+		}
+	}
+}
+
+func TestEncodeUntouchedText(t *testing.T) {
+	// Payloads an encoder would not write: UTF-16 strings that could be in
+	// ISO-8859-1, terminators, and a UTF-16 string with a big-endian BOM.
+	tit2 := "\x01\xff\xfeT\x00i\x00t\x00l\x00e\x00"
+	tpe1 := "\x00Artist\x00"
+	talb := "\x01\xfe\xff\x00A\x00l\x00b\x00u\x00m"
+	for _, ver := range []byte{3, 4} {
+		b := rawTag(ver, "TIT2", tit2, "TPE1", tpe1, "TALB", talb)
+		b[18] = 0x20 // TIT2: read-only
+		if ver >= 4 {
+			b[18] = 0x10
+		}
+		mi, err := ReadAllTags(bytes.NewReader(b), int64(len(b)))
+		if err != nil {
+			t.Fatalf("ID3v2.%d: %s", ver, err)
+		}
+
+		// Frames read and left untouched are written back byte for byte.
+		tag := mi.Tag()
+		tag.Padding = 0
+		e, err := tag.Encode()
+		if err != nil {
+			t.Fatalf("ID3v2.%d: %s", ver, err)
+		}
+		if !bytes.Equal(e, b) {
+			t.Errorf("ID3v2.%d: written as %q, want %q", ver, e, b)
+		}
+
+		// The payload of the frames set, or whose Value is changed, is built
+		// again.
+		tag.SetArtist("Artist")
+		tag.Frames[2].Value = "Älbum"
+		tag = reload(t, tag)
+		want := map[string]string{"TIT2": tit2, "TPE1": "\x00Artist", "TALB": "\x00\xc4lbum"}
+		for _, pt := range tag.Frames {
+			if string(pt.Payload) != want[pt.ID] {
+				t.Errorf("ID3v2.%d: %s payload %q, want %q", ver, pt.ID, pt.Payload, want[pt.ID])
+			}
+		}
+	}
+}
+
+func TestConvertText(t *testing.T) {
+	for _, tc := range []struct {
+		from, to byte
+		id, pl   string
+		want     string // payload after the conversion
+	}{
+		{3, 4, "TIT2", "\x01\xff\xfeT\x00\xed\x00t\x00l\x00\xe9\x00", "\x00T\xedtl\xe9"},
+		{3, 4, "TIT2", "\x01\xff\xfe\x16\x04", "\x03\xd0\x96"},
+		{3, 4, "TIT2", "\x00Title\x00", "\x00Title\x00"},
+		{4, 3, "TIT2", "\x03\xd0\x96", "\x01\xff\xfe\x16\x04"},
+		{4, 3, "TPE1", "\x00A1\x00A2", "\x00A1/A2"},
+		{4, 3, "TXXX", "\x00desc\x00value\x00", "\x00desc\x00value\x00"},
+		{4, 3, "TIT2", "\x01\xff\xfeT\x00\x00\x00", "\x01\xff\xfeT\x00\x00\x00"},
+	} {
+		b := rawTag(tc.from, tc.id, tc.pl)
+		mi, err := ReadAllTags(bytes.NewReader(b), int64(len(b)))
+		if err != nil {
+			t.Fatalf("%s %q: %s", tc.id, tc.pl, err)
+		}
+		tag := mi.Tag()
+		if _, err := tag.ConvertTo(tc.to); err != nil {
+			t.Fatalf("%s %q: %s", tc.id, tc.pl, err)
+		}
+		tag = reload(t, tag)
+		if len(tag.Frames) != 1 || string(tag.Frames[0].Payload) != tc.want {
+			t.Errorf("%s %q: converted to %q, want %q", tc.id, tc.pl, tag.Frames[0].Payload, tc.want)
+		}
+	}
+}